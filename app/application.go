@@ -1,18 +1,235 @@
 package app
 
 import (
+	"context"
+	"errors"
+	"fmt"
+
 	"github.com/gin-gonic/gin"
+	"github.com/migueloli/bookstore_users-api/config"
+	"github.com/migueloli/bookstore_users-api/datasources/mysql/usersdb"
+	"github.com/migueloli/bookstore_users-api/domain/health"
+	"github.com/migueloli/bookstore_users-api/domain/idempotency"
+	"github.com/migueloli/bookstore_users-api/domain/users"
 	"github.com/migueloli/bookstore_users-api/logger"
+	"github.com/migueloli/bookstore_users-api/metrics"
+	"github.com/migueloli/bookstore_users-api/notifications"
+	"github.com/migueloli/bookstore_users-api/services"
+	"github.com/migueloli/bookstore_users-api/utils/cryptoutils"
+	"github.com/migueloli/bookstore_users-api/utils/dateutils"
 )
 
 var (
-	router = gin.Default()
+	// router is created by StartApplication, so the commands don't print the gin banner on their
+	// output.
+	router *gin.Engine
+
+	// ifMatchRequired makes the user writes fail with 428 without an If-Match header.
+	ifMatchRequired = false
 )
 
-// StartApplication configure and start the modules for de application.
-func StartApplication() {
+// repositories groups the persistence used by the services.
+type repositories struct {
+	users              users.UserRepository
+	passwordResets     users.PasswordResetRepository
+	emailVerifications users.EmailVerificationRepository
+	roles              users.RoleRepository
+	idempotency        idempotency.Repository
+}
+
+// StartApplication configure and start the modules for de application, returning once it is shut
+// down. The database being unreachable doesn't stop it from starting, it is only not ready until
+// the database is back.
+func StartApplication(cfg *config.Config) error {
+	if err := configurePasswordPolicy(cfg.Users); err != nil {
+		return err
+	}
+
+	repos, err := newRepositories(cfg)
+	if err != nil {
+		return err
+	}
+
+	// The server listens while the database is waited for, live but not ready until it connects.
+	workers := newWorkers()
+	workers.Go(func(ctx context.Context) {
+		startDatabase(ctx, cfg, func() { grantAdminRoles(repos.roles, cfg.Users.AdminIDs) })
+	})
+	notifier := newNotifier(cfg.Users)
+	services.HealthService = services.NewHealthService(newHealthRegistry(cfg, notifier))
+
+	services.UsersService = services.NewInstrumentedUsersService(services.NewUsersService(repos.users, repos.emailVerifications, notifier, newLoginAttempts(cfg.Users)))
+	services.PasswordsService = services.NewPasswordsService(repos.users, repos.passwordResets, notifier)
+	services.AuthorizationService = services.NewAuthorizationService(repos.users, repos.roles)
+	services.TransferService = services.NewTransferService(repos.users)
+	services.IdempotencyService = services.NewIdempotencyService(repos.idempotency, cfg.Users.IdempotencyTTL)
+	startPurger(workers, cfg.Users)
+
+	ifMatchRequired = cfg.Users.RequireIfMatch
+	router = gin.Default()
 	mapUrls()
 
 	logger.Info("Starting application...")
-	router.Run(":8080")
+	return serve(cfg.Server, workers)
+}
+
+// newRepositories selects the persistence, MySQL unless the memory one is configured. The MySQL
+// client is only created, the database is waited for by connectDatabase.
+func newRepositories(cfg *config.Config) (repositories, error) {
+	if cfg.Users.Repository == config.RepositoryMemory {
+		logger.Info("Using the in-memory repositories.")
+		usersRepository := users.NewMemoryRepository()
+		return repositories{
+			users:              usersRepository,
+			passwordResets:     users.NewPasswordResetMemoryRepository(),
+			emailVerifications: users.NewEmailVerificationMemoryRepository(),
+			roles:              users.NewRoleMemoryRepository(usersRepository),
+			idempotency:        idempotency.NewMemoryRepository(),
+		}, nil
+	}
+
+	client, err := usersdb.New(cfg.Database)
+	if err != nil {
+		return repositories{}, err
+	}
+
+	metrics.RegisterDB(client, "users")
+	return repositories{
+		users:              users.NewInstrumentedRepository(users.NewMySQLRepository(usersdb.Client)),
+		passwordResets:     users.NewPasswordResetMySQLRepository(usersdb.Client),
+		emailVerifications: users.NewEmailVerificationMySQLRepository(usersdb.Client),
+		roles:              users.NewRoleMySQLRepository(usersdb.Client),
+		idempotency:        idempotency.NewMySQLRepository(usersdb.Client),
+	}, nil
+}
+
+// connectDatabase waits for the MySQL database, applying the pending migrations when configured.
+// The memory repositories have nothing to wait for.
+func connectDatabase(ctx context.Context, cfg *config.Config) error {
+	if cfg.Users.Repository != config.RepositoryMySQL {
+		return nil
+	}
+
+	return usersdb.Connect(ctx, usersdb.Client, cfg.Database)
+}
+
+// openRepositories creates the repositories and waits for the database, for the commands that
+// can't run without it.
+func openRepositories(ctx context.Context, cfg *config.Config) (repositories, error) {
+	repos, err := newRepositories(cfg)
+	if err != nil {
+		return repositories{}, err
+	}
+
+	if err := connectDatabase(ctx, cfg); err != nil {
+		return repositories{}, err
+	}
+
+	return repos, nil
+}
+
+// startDatabase waits for the database with the configured attempts and then for as long as it
+// takes, calling connected once it is reachable.
+func startDatabase(ctx context.Context, cfg *config.Config, connected func()) {
+	if err := connectDatabase(ctx, cfg); err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+
+		logger.Error("Running without the users database, not ready until it is reachable.", err)
+		reconnectDatabase(ctx, cfg.Database, connected)
+		return
+	}
+
+	connected()
+}
+
+// reconnectDatabase keeps waiting for the database the application started without, applying the
+// pending migrations and calling connected once it is back.
+func reconnectDatabase(ctx context.Context, cfg config.DatabaseConfig, connected func()) {
+	cfg.ConnectAttempts = 0
+	if err := usersdb.Connect(ctx, usersdb.Client, cfg); err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		logger.Error("Error when trying to reconnect the users database.", err)
+		return
+	}
+
+	logger.Info("Users database reachable, the application is ready.")
+	connected()
+}
+
+// newHealthRegistry registers the checks of the dependencies in use for the readiness. Only the
+// database is critical, the notifier can be down for a while without losing requests.
+func newHealthRegistry(cfg *config.Config, notifier notifications.Notifier) *health.Registry {
+	registry := health.NewRegistry(cfg.Server.HealthTimeout)
+	if cfg.Users.Repository == config.RepositoryMySQL {
+		registry.Register("mysql", true, health.CheckerFunc(usersdb.Check))
+	}
+	if checker, ok := notifier.(health.Checker); ok {
+		registry.Register("notifier", false, checker)
+	}
+
+	return registry
+}
+
+// newNotifier selects how the messages reach the users. Only local senders exist by now:
+// the application log by default or the NotifierPath file with the file notifier.
+func newNotifier(cfg config.UsersConfig) notifications.Notifier {
+	if cfg.Notifier == config.NotifierFile {
+		return notifications.NewFileNotifier(cfg.NotifierPath)
+	}
+
+	return notifications.NewLogNotifier()
+}
+
+// configurePasswordPolicy overrides the default password rules and hashing algorithm with the
+// configured ones.
+func configurePasswordPolicy(cfg config.UsersConfig) error {
+	passwords, err := cryptoutils.NewPasswordsForAlgorithm(cfg.PasswordAlgorithm)
+	if err != nil {
+		return err
+	}
+	// Generates the dummy hash now, so the first login with an unknown e-mail isn't the slowest.
+	passwords.VerifyDummy("")
+	cryptoutils.Passwords = passwords
+
+	users.Policy.MinLength = cfg.PasswordMinLength
+	users.Policy.MinClasses = cfg.PasswordMinClasses
+
+	if cfg.PasswordDenylist != "" {
+		denylist, err := users.LoadPasswordDenylist(cfg.PasswordDenylist)
+		if err != nil {
+			return fmt.Errorf("error when trying to load the password denylist: %w", err)
+		}
+		users.Policy.Denylist = denylist
+		logger.Info("Password denylist loaded.")
+	}
+
+	return nil
+}
+
+// newLoginAttempts creates the in-memory login trackers, overriding the account lockout with the
+// configured one.
+func newLoginAttempts(cfg config.UsersConfig) users.LoginAttempts {
+	accounts := users.DefaultAccountLoginThrottle
+	accounts.LockoutThreshold = cfg.LoginLockoutThreshold
+	accounts.LockoutDuration = cfg.LoginLockoutDuration
+
+	return users.LoginAttempts{
+		Accounts: users.NewMemoryLoginAttemptTracker(accounts),
+		IPs:      users.NewMemoryLoginAttemptTracker(users.DefaultIPLoginThrottle),
+	}
+}
+
+// grantAdminRoles bootstraps the admin role for the configured user IDs, so the first admins can
+// manage the roles of everyone else. A failure is only logged, the admins can be granted later.
+func grantAdminRoles(roles users.RoleRepository, adminIDs []int64) {
+	for _, adminID := range adminIDs {
+		entry := users.NewRoleAuditEntry(users.SystemActor, users.AuditActionRoleGrant, adminID, users.RoleAdmin)
+		if err := roles.Grant(adminID, users.RoleAdmin, dateutils.GetNowDBString(), entry); err != nil {
+			logger.Error(fmt.Sprintf("Error when trying to grant the admin role to the user %d.", adminID), errors.New(err.Message))
+		}
+	}
 }
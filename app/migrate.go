@@ -0,0 +1,86 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/migueloli/bookstore_users-api/config"
+	"github.com/migueloli/bookstore_users-api/datasources/mysql/usersdb"
+)
+
+const (
+	migrateUsage = "usage: migrate up | down [steps] | status"
+)
+
+// RunMigrations executes the migrate command with its arguments, returning the exit code.
+func RunMigrations(cfg *config.Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	if cfg.Users.Repository != config.RepositoryMySQL {
+		fmt.Fprintln(os.Stderr, "migrate needs the mysql repository.")
+		return 2
+	}
+
+	// The command decides what to migrate, the connection must not apply the pending migrations.
+	database := cfg.Database
+	database.AutoMigrate = false
+	client, err := usersdb.Open(context.Background(), database)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer client.Close()
+
+	switch args[0] {
+	case "up":
+		applied, err := usersdb.MigrateUp(usersdb.Client)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("%d migrations applied.\n", applied)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			var err error
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps <= 0 {
+				fmt.Fprintln(os.Stderr, migrateUsage)
+				return 2
+			}
+		}
+
+		reverted, err := usersdb.MigrateDown(usersdb.Client, steps)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("%d migrations reverted.\n", reverted)
+
+	case "status":
+		status, err := usersdb.GetMigrationStatus(usersdb.Client)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+
+		for _, migration := range status {
+			state := "pending"
+			if migration.Applied {
+				state = "applied at " + migration.AppliedAt
+			}
+			fmt.Printf("%04d_%s\t%s\n", migration.Version, migration.Name, state)
+		}
+
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	return 0
+}
@@ -0,0 +1,62 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/migueloli/bookstore_users-api/config"
+	"github.com/migueloli/bookstore_users-api/logger"
+	"github.com/migueloli/bookstore_users-api/services"
+)
+
+// startPurger runs in background the hard delete of the users deleted longer than the
+// DeletedRetention ago and of the expired idempotency records, every PurgeInterval until the
+// workers stop. A zero interval disables it.
+func startPurger(workers *workers, cfg config.UsersConfig) {
+	if cfg.PurgeInterval <= 0 {
+		logger.Info("Purger of deleted users and idempotency records disabled.")
+		return
+	}
+
+	workers.Go(func(ctx context.Context) {
+		ticker := time.NewTicker(cfg.PurgeInterval)
+		defer ticker.Stop()
+
+		for {
+			purgeDeletedUsers(cfg.DeletedRetention)
+			purgeIdempotencyRecords()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	})
+}
+
+func purgeDeletedUsers(retention time.Duration) {
+	purged, err := services.UsersService.PurgeDeletedUsers(retention)
+	if err != nil {
+		logger.Error("Error when trying to purge the deleted users.", errors.New(err.Message))
+		return
+	}
+
+	if purged > 0 {
+		logger.Info("Purged " + strconv.FormatInt(purged, 10) + " deleted users.")
+	}
+}
+
+func purgeIdempotencyRecords() {
+	purged, err := services.IdempotencyService.PurgeExpired()
+	if err != nil {
+		logger.Error("Error when trying to purge the expired idempotency records.", errors.New(err.Message))
+		return
+	}
+
+	if purged > 0 {
+		logger.Info("Purged " + strconv.FormatInt(purged, 10) + " expired idempotency records.")
+	}
+}
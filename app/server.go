@@ -0,0 +1,95 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/migueloli/bookstore_users-api/config"
+	"github.com/migueloli/bookstore_users-api/datasources/mysql/usersdb"
+	"github.com/migueloli/bookstore_users-api/logger"
+	"github.com/migueloli/bookstore_users-api/services"
+)
+
+// workers are the background goroutines of the application, stopped on shutdown.
+type workers struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newWorkers() *workers {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &workers{ctx: ctx, cancel: cancel}
+}
+
+// Go runs the worker until its context is canceled by Stop.
+func (w *workers) Go(worker func(context.Context)) {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		worker(w.ctx)
+	}()
+}
+
+// Stop cancels the workers and waits for them to return.
+func (w *workers) Stop() {
+	w.cancel()
+	w.wg.Wait()
+}
+
+// serve runs the HTTP server until SIGINT or SIGTERM, then shuts the application down in order:
+// the readiness fails first and, after ShutdownDelay, the in-flight requests are drained for up
+// to ShutdownTimeout. The workers, the database pool and the logger are closed last. It returns
+// the error of the server or of the draining.
+func serve(cfg config.ServerConfig, workers *workers) error {
+	server := &http.Server{Addr: cfg.Address, Handler: router}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	var serveErr error
+	select {
+	case err := <-serverErr:
+		logger.Error("Error when trying to run the HTTP server.", err)
+		serveErr = err
+
+	case <-ctx.Done():
+		// A second signal kills the application right away.
+		stop()
+
+		logger.Info("Shutting down, the readiness fails from now on.")
+		services.HealthService.ShutDown()
+		time.Sleep(cfg.ShutdownDelay)
+
+		drainCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+		defer cancel()
+
+		if err := server.Shutdown(drainCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Error when trying to drain the in-flight requests.", err)
+			serveErr = err
+		}
+	}
+
+	workers.Stop()
+	if usersdb.Client != nil {
+		if err := usersdb.Client.Close(); err != nil {
+			logger.Error("Error when trying to close the users database.", err)
+		}
+	}
+
+	logger.Info("Application stopped.")
+	logger.Sync()
+
+	return serveErr
+}
@@ -1,18 +1,47 @@
 package app
 
 import (
+	"github.com/gin-gonic/gin"
+	"github.com/migueloli/bookstore_users-api/controllers/health"
 	"github.com/migueloli/bookstore_users-api/controllers/ping"
 	"github.com/migueloli/bookstore_users-api/controllers/users"
+	domain "github.com/migueloli/bookstore_users-api/domain/users"
+	"github.com/migueloli/bookstore_users-api/metrics"
+	"github.com/migueloli/bookstore_users-api/middlewares"
 )
 
 func mapUrls() {
+	router.Use(middlewares.Metrics(), middlewares.RequestID())
+
 	router.GET("/ping", ping.Ping)
+	router.GET("/health/live", health.Live)
+	router.GET("/health/ready", health.Ready)
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
 
-	router.POST("/users", users.Create)
-	router.GET("/users/:user_id", users.Get)
-	router.PUT("/users/:user_id", users.Update)
-	router.PATCH("/users/:user_id", users.Update)
-	router.DELETE("/users/:user_id", users.Delete)
-	router.GET("internal/users/search", users.Search)
+	router.POST("/users", middlewares.Idempotency(), users.Create)
+	router.GET("/users/verify", users.VerifyEmail)
+	router.POST("/users/verify/resend", middlewares.Idempotency(), users.ResendVerification)
+	router.GET("/users/:user_id", middlewares.Authenticate(), users.Get)
+	router.PUT("/users/:user_id", middlewares.Authenticate(), middlewares.RequireOwnerOrPermission("user_id", domain.PermissionUsersUpdate), middlewares.RequireIfMatch(ifMatchRequired), users.Update)
+	router.PATCH("/users/:user_id", middlewares.Authenticate(), middlewares.RequireOwnerOrPermission("user_id", domain.PermissionUsersUpdate), middlewares.RequireIfMatch(ifMatchRequired), users.Patch)
+	router.DELETE("/users/:user_id", middlewares.Authenticate(), middlewares.RequirePermission(domain.PermissionUsersDelete), middlewares.RequireIfMatch(ifMatchRequired), users.Delete)
+	router.POST("/users/:user_id/restore", middlewares.Authenticate(), middlewares.RequirePermission(domain.PermissionUsersRestore), middlewares.Idempotency(), users.Restore)
+	router.PUT("/users/:user_id/password", middlewares.Authenticate(), middlewares.RequireOwner("user_id"), users.ChangePassword)
+	router.GET("/users/:user_id/roles", middlewares.Authenticate(), middlewares.RequireOwnerOrPermission("user_id", domain.PermissionRolesManage), users.GetRoles)
+	router.GET("/internal/users", middlewares.Authenticate(), middlewares.RequirePermission(domain.PermissionUsersSearch), users.GetBatch)
+	router.POST("/internal/users/batch", middlewares.Authenticate(), middlewares.RequirePermission(domain.PermissionUsersImport), middlewares.Idempotency(), users.CreateBatch)
+	router.GET("/internal/users/export", middlewares.Authenticate(), middlewares.RequirePermission(domain.PermissionUsersExport), users.Export)
+	router.POST("/internal/users/import", middlewares.Authenticate(), middlewares.RequirePermission(domain.PermissionUsersImport), middlewares.Idempotency(), users.Import)
+	router.GET("internal/users/search", middlewares.Authenticate(), middlewares.RequirePermission(domain.PermissionUsersSearch), users.Search)
+	router.GET("/internal/users/:user_id/audit", middlewares.Authenticate(), middlewares.RequirePermission(domain.PermissionUsersAudit), users.GetAudit)
+	router.POST("/internal/users/:user_id/unlock", middlewares.Authenticate(), middlewares.RequirePermission(domain.PermissionUsersUnlock), middlewares.Idempotency(), users.Unlock)
+	router.POST("/internal/users/:user_id/activate", middlewares.Authenticate(), middlewares.RequirePermission(domain.PermissionUsersModerate), middlewares.Idempotency(), users.ChangeStatus(domain.OperationActivate))
+	router.POST("/internal/users/:user_id/suspend", middlewares.Authenticate(), middlewares.RequirePermission(domain.PermissionUsersModerate), middlewares.Idempotency(), users.ChangeStatus(domain.OperationSuspend))
+	router.POST("/internal/users/:user_id/ban", middlewares.Authenticate(), middlewares.RequirePermission(domain.PermissionUsersModerate), middlewares.Idempotency(), users.ChangeStatus(domain.OperationBan))
+	router.POST("/internal/users/:user_id/reactivate", middlewares.Authenticate(), middlewares.RequirePermission(domain.PermissionUsersModerate), middlewares.Idempotency(), users.ChangeStatus(domain.OperationReactivate))
+	router.PUT("/internal/users/:user_id/roles/:role", middlewares.Authenticate(), middlewares.RequirePermission(domain.PermissionRolesManage), users.GrantRole)
+	router.DELETE("/internal/users/:user_id/roles/:role", middlewares.Authenticate(), middlewares.RequirePermission(domain.PermissionRolesManage), users.RevokeRole)
 	router.POST("/users/login", users.Login)
+	router.POST("/users/password/forgot", middlewares.Idempotency(), users.ForgotPassword)
+	router.POST("/users/password/reset", middlewares.Idempotency(), users.ResetPassword)
 }
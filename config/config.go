@@ -0,0 +1,200 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/migueloli/bookstore_users-api/utils/cryptoutils"
+)
+
+// Repositories and notifiers that can be configured.
+const (
+	RepositoryMySQL  = "mysql"
+	RepositoryMemory = "memory"
+
+	NotifierLog  = "log"
+	NotifierFile = "file"
+)
+
+// Config is the typed configuration of the application, loaded by Load and passed to every
+// package needing a setting.
+type Config struct {
+	Server   ServerConfig   `yaml:"server"`
+	Logger   LoggerConfig   `yaml:"logger"`
+	Database DatabaseConfig `yaml:"database"`
+	Users    UsersConfig    `yaml:"users"`
+}
+
+// ServerConfig is the configuration of the HTTP server.
+type ServerConfig struct {
+	Address       string        `yaml:"address"`
+	HealthTimeout time.Duration `yaml:"health_timeout"`
+
+	// ShutdownDelay is the time between the readiness failing and the server no longer accepting
+	// requests, for the load balancers to notice.
+	ShutdownDelay time.Duration `yaml:"shutdown_delay"`
+	// ShutdownTimeout is the time given to the in-flight requests to finish.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+// LoggerConfig is the configuration of the application logger.
+type LoggerConfig struct {
+	Level       string   `yaml:"level"`
+	Encoding    string   `yaml:"encoding"`
+	OutputPaths []string `yaml:"output_paths"`
+}
+
+// DatabaseConfig is the configuration of the MySQL users database.
+type DatabaseConfig struct {
+	Username    string `yaml:"username"`
+	Password    string `yaml:"password"`
+	Host        string `yaml:"host"`
+	Schema      string `yaml:"schema"`
+	AutoMigrate bool   `yaml:"auto_migrate"`
+
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+
+	ConnectAttempts   int           `yaml:"connect_attempts"`
+	ConnectTimeout    time.Duration `yaml:"connect_timeout"`
+	ConnectBackoff    time.Duration `yaml:"connect_backoff"`
+	ConnectMaxBackoff time.Duration `yaml:"connect_max_backoff"`
+}
+
+// UsersConfig is the configuration of the users domain and its services.
+type UsersConfig struct {
+	Repository   string `yaml:"repository"`
+	Notifier     string `yaml:"notifier"`
+	NotifierPath string `yaml:"notifier_path"`
+
+	PasswordMinLength  int    `yaml:"password_min_length"`
+	PasswordMinClasses int    `yaml:"password_min_classes"`
+	PasswordDenylist   string `yaml:"password_denylist"`
+	PasswordAlgorithm  string `yaml:"password_algorithm"`
+
+	LoginLockoutThreshold int           `yaml:"login_lockout_threshold"`
+	LoginLockoutDuration  time.Duration `yaml:"login_lockout_duration"`
+
+	AdminIDs []int64 `yaml:"admin_ids"`
+
+	DeletedRetention time.Duration `yaml:"deleted_retention"`
+	PurgeInterval    time.Duration `yaml:"purge_interval"`
+
+	RequireIfMatch bool          `yaml:"require_if_match"`
+	IdempotencyTTL time.Duration `yaml:"idempotency_ttl"`
+}
+
+// Default returns the configuration used for every setting left out of the file, environment and
+// flags. The users values match the defaults of the domain.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Address:         ":8080",
+			HealthTimeout:   2 * time.Second,
+			ShutdownDelay:   3 * time.Second,
+			ShutdownTimeout: 30 * time.Second,
+		},
+		Logger: LoggerConfig{
+			Level:       "info",
+			Encoding:    "json",
+			OutputPaths: []string{"stdout"},
+		},
+		Database: DatabaseConfig{
+			MaxOpenConns:      20,
+			MaxIdleConns:      10,
+			ConnMaxLifetime:   5 * time.Minute,
+			ConnMaxIdleTime:   time.Minute,
+			ConnectAttempts:   5,
+			ConnectTimeout:    5 * time.Second,
+			ConnectBackoff:    time.Second,
+			ConnectMaxBackoff: 30 * time.Second,
+		},
+		Users: UsersConfig{
+			Repository:            RepositoryMySQL,
+			Notifier:              NotifierLog,
+			PasswordMinLength:     10,
+			PasswordMinClasses:    3,
+			PasswordAlgorithm:     cryptoutils.AlgorithmArgon2id,
+			LoginLockoutThreshold: 10,
+			LoginLockoutDuration:  15 * time.Minute,
+			DeletedRetention:      30 * 24 * time.Hour,
+			PurgeInterval:         time.Hour,
+			IdempotencyTTL:        24 * time.Hour,
+		},
+	}
+}
+
+// Validate checks every setting, returning a single error listing all the problems found.
+func (c *Config) Validate() error {
+	var problems []string
+	require := func(ok bool, key string, message string) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s %s (%s)", key, message, describe(key)))
+		}
+	}
+
+	require(c.Server.Address != "", "server.address", "is required")
+	require(c.Server.HealthTimeout > 0, "server.health_timeout", "should be greater than 0")
+	require(c.Server.ShutdownDelay >= 0, "server.shutdown_delay", "should not be negative")
+	require(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout", "should be greater than 0")
+
+	require(oneOf(c.Logger.Level, "debug", "info", "warn", "error"), "logger.level", "should be debug, info, warn or error")
+	require(oneOf(c.Logger.Encoding, "json", "console"), "logger.encoding", "should be json or console")
+	require(len(c.Logger.OutputPaths) > 0, "logger.output_paths", "is required")
+
+	require(oneOf(c.Users.Repository, RepositoryMySQL, RepositoryMemory), "users.repository", "should be mysql or memory")
+	if c.Users.Repository == RepositoryMySQL {
+		require(c.Database.Username != "", "database.username", "is required by the mysql repository")
+		require(c.Database.Host != "", "database.host", "is required by the mysql repository")
+		require(c.Database.Schema != "", "database.schema", "is required by the mysql repository")
+	}
+
+	require(c.Database.MaxOpenConns >= 0, "database.max_open_conns", "should not be negative, 0 is unlimited")
+	require(c.Database.MaxIdleConns >= 0, "database.max_idle_conns", "should not be negative")
+	require(c.Database.MaxOpenConns == 0 || c.Database.MaxIdleConns <= c.Database.MaxOpenConns, "database.max_idle_conns", "should not be greater than database.max_open_conns")
+	require(c.Database.ConnMaxLifetime >= 0, "database.conn_max_lifetime", "should not be negative, 0 is unlimited")
+	require(c.Database.ConnMaxIdleTime >= 0, "database.conn_max_idle_time", "should not be negative, 0 is unlimited")
+	require(c.Database.ConnectAttempts > 0, "database.connect_attempts", "should be greater than 0")
+	require(c.Database.ConnectTimeout > 0, "database.connect_timeout", "should be greater than 0")
+	require(c.Database.ConnectBackoff > 0, "database.connect_backoff", "should be greater than 0")
+	require(c.Database.ConnectMaxBackoff >= c.Database.ConnectBackoff, "database.connect_max_backoff", "should not be less than database.connect_backoff")
+
+	require(oneOf(c.Users.Notifier, NotifierLog, NotifierFile), "users.notifier", "should be log or file")
+	if c.Users.Notifier == NotifierFile {
+		require(c.Users.NotifierPath != "", "users.notifier_path", "is required by the file notifier")
+	}
+
+	require(c.Users.PasswordMinLength > 0, "users.password_min_length", "should be greater than 0")
+	require(c.Users.PasswordMinClasses >= 1 && c.Users.PasswordMinClasses <= 4, "users.password_min_classes", "should be between 1 and 4")
+	require(oneOf(c.Users.PasswordAlgorithm, cryptoutils.AlgorithmArgon2id, cryptoutils.AlgorithmBcrypt), "users.password_algorithm", "should be argon2id or bcrypt")
+	require(c.Users.LoginLockoutThreshold > 0, "users.login_lockout_threshold", "should be greater than 0")
+	require(c.Users.LoginLockoutDuration > 0, "users.login_lockout_duration", "should be greater than 0")
+
+	for _, adminID := range c.Users.AdminIDs {
+		require(adminID > 0, "users.admin_ids", "should only have IDs greater than 0")
+	}
+
+	require(c.Users.DeletedRetention > 0, "users.deleted_retention", "should be greater than 0")
+	require(c.Users.PurgeInterval >= 0, "users.purge_interval", "should not be negative, 0 disables the purger")
+	require(c.Users.IdempotencyTTL > 0, "users.idempotency_ttl", "should be greater than 0")
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration:\n  - " + strings.Join(problems, "\n  - "))
+	}
+
+	return nil
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, candidate := range allowed {
+		if value == candidate {
+			return true
+		}
+	}
+
+	return false
+}
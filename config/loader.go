@@ -0,0 +1,242 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// envConfigFile is the environment variable with the path of the YAML file, overridden by the
+	// -config flag.
+	envConfigFile = "users_config"
+
+	usage = "usage: bookstore_users-api [-config file] [-setting value ...] [migrate | export | import] [args]"
+)
+
+// ErrInvalidFlags is returned by Load when the flags can't be parsed, after printing the error and
+// the usage.
+var ErrInvalidFlags = errors.New("invalid flags")
+
+// setting binds a field of the Config to its environment variable and command-line flag. The key
+// is the path of the field in the YAML file and the name of its flag.
+type setting struct {
+	key   string
+	env   string
+	usage string
+	field func(*Config) interface{}
+}
+
+var settings = []setting{
+	{key: "server.address", env: "users_address", usage: "address of the HTTP server", field: func(c *Config) interface{} { return &c.Server.Address }},
+	{key: "server.health_timeout", env: "users_health_timeout", usage: "timeout of each readiness check", field: func(c *Config) interface{} { return &c.Server.HealthTimeout }},
+	{key: "server.shutdown_delay", env: "users_shutdown_delay", usage: "time the readiness fails before the server stops accepting requests", field: func(c *Config) interface{} { return &c.Server.ShutdownDelay }},
+	{key: "server.shutdown_timeout", env: "users_shutdown_timeout", usage: "time given to the in-flight requests on shutdown", field: func(c *Config) interface{} { return &c.Server.ShutdownTimeout }},
+
+	{key: "logger.level", env: "users_log_level", usage: "debug, info, warn or error", field: func(c *Config) interface{} { return &c.Logger.Level }},
+	{key: "logger.encoding", env: "users_log_encoding", usage: "json or console", field: func(c *Config) interface{} { return &c.Logger.Encoding }},
+	{key: "logger.output_paths", env: "users_log_output_paths", usage: "comma separated paths, stdout or stderr", field: func(c *Config) interface{} { return &c.Logger.OutputPaths }},
+
+	{key: "database.username", env: "mysql_users_username", usage: "MySQL username", field: func(c *Config) interface{} { return &c.Database.Username }},
+	{key: "database.password", env: "mysql_users_password", usage: "MySQL password", field: func(c *Config) interface{} { return &c.Database.Password }},
+	{key: "database.host", env: "mysql_users_host", usage: "MySQL host:port", field: func(c *Config) interface{} { return &c.Database.Host }},
+	{key: "database.schema", env: "mysql_users_schema", usage: "MySQL schema", field: func(c *Config) interface{} { return &c.Database.Schema }},
+	{key: "database.auto_migrate", env: "mysql_users_auto_migrate", usage: "apply the pending migrations on start", field: func(c *Config) interface{} { return &c.Database.AutoMigrate }},
+	{key: "database.max_open_conns", env: "mysql_users_max_open_conns", usage: "maximum open connections, 0 is unlimited", field: func(c *Config) interface{} { return &c.Database.MaxOpenConns }},
+	{key: "database.max_idle_conns", env: "mysql_users_max_idle_conns", usage: "maximum idle connections", field: func(c *Config) interface{} { return &c.Database.MaxIdleConns }},
+	{key: "database.conn_max_lifetime", env: "mysql_users_conn_max_lifetime", usage: "time a connection is reused, 0 is unlimited", field: func(c *Config) interface{} { return &c.Database.ConnMaxLifetime }},
+	{key: "database.conn_max_idle_time", env: "mysql_users_conn_max_idle_time", usage: "time a connection stays idle, 0 is unlimited", field: func(c *Config) interface{} { return &c.Database.ConnMaxIdleTime }},
+	{key: "database.connect_attempts", env: "mysql_users_connect_attempts", usage: "pings of the database on start", field: func(c *Config) interface{} { return &c.Database.ConnectAttempts }},
+	{key: "database.connect_timeout", env: "mysql_users_connect_timeout", usage: "timeout of each ping on start", field: func(c *Config) interface{} { return &c.Database.ConnectTimeout }},
+	{key: "database.connect_backoff", env: "mysql_users_connect_backoff", usage: "first wait between the pings, doubled on each attempt", field: func(c *Config) interface{} { return &c.Database.ConnectBackoff }},
+	{key: "database.connect_max_backoff", env: "mysql_users_connect_max_backoff", usage: "maximum wait between the pings", field: func(c *Config) interface{} { return &c.Database.ConnectMaxBackoff }},
+
+	{key: "users.repository", env: "users_repository", usage: "mysql or memory", field: func(c *Config) interface{} { return &c.Users.Repository }},
+	{key: "users.notifier", env: "users_notifier", usage: "log or file", field: func(c *Config) interface{} { return &c.Users.Notifier }},
+	{key: "users.notifier_path", env: "users_notifier_path", usage: "file of the file notifier", field: func(c *Config) interface{} { return &c.Users.NotifierPath }},
+	{key: "users.password_min_length", env: "users_password_min_length", usage: "minimum password length", field: func(c *Config) interface{} { return &c.Users.PasswordMinLength }},
+	{key: "users.password_min_classes", env: "users_password_min_classes", usage: "minimum character classes of the passwords", field: func(c *Config) interface{} { return &c.Users.PasswordMinClasses }},
+	{key: "users.password_denylist", env: "users_password_denylist", usage: "file of the denied passwords", field: func(c *Config) interface{} { return &c.Users.PasswordDenylist }},
+	{key: "users.password_algorithm", env: "users_password_algorithm", usage: "argon2id or bcrypt, the other one is only verified", field: func(c *Config) interface{} { return &c.Users.PasswordAlgorithm }},
+	{key: "users.login_lockout_threshold", env: "users_login_lockout_threshold", usage: "failed logins locking the account", field: func(c *Config) interface{} { return &c.Users.LoginLockoutThreshold }},
+	{key: "users.login_lockout_duration", env: "users_login_lockout_duration", usage: "duration of the account lockout", field: func(c *Config) interface{} { return &c.Users.LoginLockoutDuration }},
+	{key: "users.admin_ids", env: "users_admin_ids", usage: "comma separated IDs of the bootstrap admins", field: func(c *Config) interface{} { return &c.Users.AdminIDs }},
+	{key: "users.deleted_retention", env: "users_deleted_retention", usage: "time the deleted users are kept", field: func(c *Config) interface{} { return &c.Users.DeletedRetention }},
+	{key: "users.purge_interval", env: "users_purge_interval", usage: "interval of the purger, 0 disables it", field: func(c *Config) interface{} { return &c.Users.PurgeInterval }},
+	{key: "users.require_if_match", env: "users_require_if_match", usage: "require If-Match on the user writes", field: func(c *Config) interface{} { return &c.Users.RequireIfMatch }},
+	{key: "users.idempotency_ttl", env: "users_idempotency_ttl", usage: "time the idempotency records are kept", field: func(c *Config) interface{} { return &c.Users.IdempotencyTTL }},
+}
+
+// Load builds the Config from the defaults, the YAML file, the environment variables and the
+// command-line flags, each one overriding the previous. The flags must come before the command,
+// returned with its arguments.
+func Load(args []string) (*Config, []string, error) {
+	flags := flag.NewFlagSet("bookstore_users-api", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+
+	path := flags.String("config", os.Getenv(envConfigFile), "YAML configuration file")
+	flagValues := make(map[string]string)
+	for index := range settings {
+		flags.Var(&flagValue{setting: &settings[index], values: flagValues}, settings[index].key, settings[index].usage)
+	}
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		return nil, nil, err
+	} else if err != nil {
+		return nil, nil, ErrInvalidFlags
+	}
+
+	cfg := Default()
+	if *path != "" {
+		if err := loadFile(cfg, *path); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, setting := range settings {
+		if value := os.Getenv(setting.env); value != "" {
+			if err := parse(setting.field(cfg), value); err != nil {
+				return nil, nil, fmt.Errorf("invalid value %q of %s in the environment: %s", value, setting.env, err)
+			}
+		}
+	}
+
+	for _, setting := range settings {
+		if value, exists := flagValues[setting.key]; exists {
+			if err := parse(setting.field(cfg), value); err != nil {
+				return nil, nil, fmt.Errorf("invalid value %q of the -%s flag: %s", value, setting.key, err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	return cfg, flags.Args(), nil
+}
+
+// loadFile overrides the defaults with the settings of the YAML file, rejecting the unknown ones.
+func loadFile(cfg *Config, path string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error when trying to read the configuration file: %s", err)
+	}
+
+	if err := yaml.UnmarshalStrict(content, cfg); err != nil {
+		return fmt.Errorf("invalid configuration file %s: %s", path, err)
+	}
+
+	return nil
+}
+
+// describe tells where the setting of the key can be informed, for the validation errors.
+func describe(key string) string {
+	for _, setting := range settings {
+		if setting.key == key {
+			return fmt.Sprintf("set %s in the file, the %s variable or the -%s flag", key, setting.env, key)
+		}
+	}
+
+	return "set " + key + " in the file"
+}
+
+// parse sets the field pointed by target to the value from the environment or a flag. The lists
+// are comma separated.
+func parse(target interface{}, value string) error {
+	switch field := target.(type) {
+	case *string:
+		*field = value
+
+	case *bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("should be true or false")
+		}
+		*field = parsed
+
+	case *int:
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("should be an integer")
+		}
+		*field = parsed
+
+	case *time.Duration:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.New("should be a duration like 90s, 15m or 24h")
+		}
+		*field = parsed
+
+	case *[]string:
+		*field = make([]string, 0)
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*field = append(*field, item)
+			}
+		}
+
+	case *[]int64:
+		*field = make([]int64, 0)
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+
+			parsed, err := strconv.ParseInt(item, 10, 64)
+			if err != nil {
+				return errors.New("should be comma separated integers")
+			}
+			*field = append(*field, parsed)
+		}
+
+	default:
+		return fmt.Errorf("unsupported setting type %T", target)
+	}
+
+	return nil
+}
+
+// flagValue keeps the value of the flag to apply it after the file and the environment. It is
+// checked right away, so a bad flag fails with the usage.
+type flagValue struct {
+	setting *setting
+	values  map[string]string
+}
+
+func (v *flagValue) String() string {
+	if v.setting == nil {
+		return ""
+	}
+
+	return v.values[v.setting.key]
+}
+
+func (v *flagValue) Set(value string) error {
+	if err := parse(v.setting.field(Default()), value); err != nil {
+		return err
+	}
+
+	v.values[v.setting.key] = value
+	return nil
+}
+
+// IsBoolFlag allows the boolean settings as flags without a value.
+func (v *flagValue) IsBoolFlag() bool {
+	if v.setting == nil {
+		return false
+	}
+
+	_, isBool := v.setting.field(Default()).(*bool)
+	return isBool
+}
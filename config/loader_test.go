@@ -0,0 +1,234 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testConfigFile = `
+server:
+  address: ":1001"
+  shutdown_delay: 1s
+users:
+  repository: memory
+  admin_ids: [1, 2]
+`
+
+// setEnv sets the environment variable for the test, restoring the previous value at the end.
+func setEnv(t *testing.T, key string, value string) {
+	t.Helper()
+
+	previous, existed := os.LookupEnv(key)
+	t.Cleanup(func() {
+		if existed {
+			os.Setenv(key, previous)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+
+	if value == "" {
+		os.Unsetenv(key)
+	} else {
+		os.Setenv(key, value)
+	}
+}
+
+// clearEnv unsets every variable read by Load, so the environment running the tests doesn't leak
+// into them.
+func clearEnv(t *testing.T) {
+	t.Helper()
+
+	setEnv(t, envConfigFile, "")
+	for _, setting := range settings {
+		setEnv(t, setting.env, "")
+	}
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("writing the configuration file: %s", err)
+	}
+
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	tests := []struct {
+		name          string
+		file          bool
+		env           map[string]string
+		args          []string
+		address       string
+		shutdownDelay time.Duration
+		adminIDs      []int64
+	}{
+		{name: "defaults", env: map[string]string{"users_repository": "memory"},
+			address: ":8080", shutdownDelay: Default().Server.ShutdownDelay, adminIDs: []int64{}},
+		{name: "file over the defaults", file: true,
+			address: ":1001", shutdownDelay: time.Second, adminIDs: []int64{1, 2}},
+		{name: "environment over the file", file: true, env: map[string]string{"users_address": ":1002", "users_admin_ids": "3"},
+			address: ":1002", shutdownDelay: time.Second, adminIDs: []int64{3}},
+		{name: "flags over the environment", file: true, env: map[string]string{"users_address": ":1002", "users_shutdown_delay": "2s"},
+			args: []string{"-server.address", ":1003", "-users.admin_ids", "4,5"}, address: ":1003", shutdownDelay: 2 * time.Second, adminIDs: []int64{4, 5}},
+		{name: "flags over the file", file: true, args: []string{"-server.shutdown_delay=3s"},
+			address: ":1001", shutdownDelay: 3 * time.Second, adminIDs: []int64{1, 2}},
+		{name: "empty environment is unset", file: true, env: map[string]string{"users_address": ""},
+			address: ":1001", shutdownDelay: time.Second, adminIDs: []int64{1, 2}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clearEnv(t)
+			for key, value := range test.env {
+				setEnv(t, key, value)
+			}
+
+			args := test.args
+			if test.file {
+				args = append([]string{"-config", writeConfigFile(t, testConfigFile)}, args...)
+			}
+
+			cfg, _, err := Load(args)
+			if err != nil {
+				t.Fatalf("loading: %s", err)
+			}
+
+			if cfg.Server.Address != test.address {
+				t.Errorf("got address %q, want %q", cfg.Server.Address, test.address)
+			}
+			if cfg.Server.ShutdownDelay != test.shutdownDelay {
+				t.Errorf("got shutdown delay %s, want %s", cfg.Server.ShutdownDelay, test.shutdownDelay)
+			}
+			if fmt.Sprint(cfg.Users.AdminIDs) != fmt.Sprint(test.adminIDs) {
+				t.Errorf("got admin IDs %v, want %v", cfg.Users.AdminIDs, test.adminIDs)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFileAndCommand(t *testing.T) {
+	clearEnv(t)
+	setEnv(t, envConfigFile, writeConfigFile(t, "server:\n  address: \":2001\"\nusers:\n  repository: memory\n"))
+
+	cfg, args, err := Load([]string{"-users.notifier", "file", "-users.notifier_path", "out.log", "migrate", "up"})
+	if err != nil {
+		t.Fatalf("loading: %s", err)
+	}
+	if cfg.Server.Address != ":2001" {
+		t.Errorf("got address %q from the file of the %s variable, want :2001", cfg.Server.Address, envConfigFile)
+	}
+	if fmt.Sprint(args) != "[migrate up]" {
+		t.Errorf("got args %v, want [migrate up]", args)
+	}
+
+	cfg, _, err = Load([]string{"-config", writeConfigFile(t, "server:\n  address: \":2002\"\nusers:\n  repository: memory\n")})
+	if err != nil {
+		t.Fatalf("loading: %s", err)
+	}
+	if cfg.Server.Address != ":2002" {
+		t.Errorf("got address %q, want :2002 from the -config flag over the %s variable", cfg.Server.Address, envConfigFile)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{name: "unknown file setting", file: "server:\n  adress: \":1\"\n",
+			want: "field adress not found"},
+		{name: "invalid file value", file: "server:\n  shutdown_delay: soon\n",
+			want: "invalid configuration file"},
+		{name: "missing file", args: []string{"-config", "missing.yml"},
+			want: "error when trying to read the configuration file"},
+		{name: "invalid environment value", env: map[string]string{"users_password_min_length": "ten"},
+			want: `invalid value "ten" of users_password_min_length in the environment: should be an integer`},
+		{name: "invalid environment duration", env: map[string]string{"users_purge_interval": "1 hour"},
+			want: `invalid value "1 hour" of users_purge_interval in the environment: should be a duration like 90s, 15m or 24h`},
+		{name: "invalid environment list", env: map[string]string{"users_admin_ids": "1,two"},
+			want: `invalid value "1,two" of users_admin_ids in the environment: should be comma separated integers`},
+		{name: "invalid flag value", args: []string{"-users.require_if_match=yes"},
+			want: ErrInvalidFlags.Error()},
+		{name: "unknown flag", args: []string{"-server.adress", ":1"},
+			want: ErrInvalidFlags.Error()},
+		{name: "validation after the flags", env: map[string]string{"users_repository": "memory"}, args: []string{"-logger.level", "verbose"},
+			want: "logger.level should be debug, info, warn or error (set logger.level in the file, the users_log_level variable or the -logger.level flag)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clearEnv(t)
+			for key, value := range test.env {
+				setEnv(t, key, value)
+			}
+
+			args := test.args
+			if test.file != "" {
+				args = append([]string{"-config", writeConfigFile(t, test.file)}, args...)
+			}
+
+			// The invalid flags print the usage.
+			stderr := os.Stderr
+			if devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0); err == nil {
+				os.Stderr = devNull
+				defer devNull.Close()
+			}
+			_, _, err := Load(args)
+			os.Stderr = stderr
+
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got error %v, want it to contain %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestValidateMessages(t *testing.T) {
+	cfg := Default()
+	cfg.Server.Address = ""
+	cfg.Database.MaxOpenConns = 5
+	cfg.Database.MaxIdleConns = 10
+	cfg.Users.Notifier = NotifierFile
+	cfg.Users.PasswordAlgorithm = "md5"
+	cfg.Users.AdminIDs = []int64{1, -1}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("got no error, want the problems listed")
+	}
+
+	want := `invalid configuration:
+  - server.address is required (set server.address in the file, the users_address variable or the -server.address flag)
+  - database.username is required by the mysql repository (set database.username in the file, the mysql_users_username variable or the -database.username flag)
+  - database.host is required by the mysql repository (set database.host in the file, the mysql_users_host variable or the -database.host flag)
+  - database.schema is required by the mysql repository (set database.schema in the file, the mysql_users_schema variable or the -database.schema flag)
+  - database.max_idle_conns should not be greater than database.max_open_conns (set database.max_idle_conns in the file, the mysql_users_max_idle_conns variable or the -database.max_idle_conns flag)
+  - users.notifier_path is required by the file notifier (set users.notifier_path in the file, the users_notifier_path variable or the -users.notifier_path flag)
+  - users.password_algorithm should be argon2id or bcrypt (set users.password_algorithm in the file, the users_password_algorithm variable or the -users.password_algorithm flag)
+  - users.admin_ids should only have IDs greater than 0 (set users.admin_ids in the file, the users_admin_ids variable or the -users.admin_ids flag)`
+	if err.Error() != want {
+		t.Errorf("got error:\n%s\nwant:\n%s", err, want)
+	}
+
+	cfg.Users.Repository = RepositoryMemory
+	cfg.Server.Address = ":8080"
+	cfg.Database.MaxIdleConns = 5
+	cfg.Users.NotifierPath = "notify.log"
+	cfg.Users.PasswordAlgorithm = "bcrypt"
+	cfg.Users.AdminIDs = []int64{1}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("got error %s once fixed, want none", err)
+	}
+}
@@ -0,0 +1,26 @@
+package health
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/migueloli/bookstore_users-api/domain/health"
+	"github.com/migueloli/bookstore_users-api/services"
+)
+
+// Live is the liveness check, failing only when the process can't answer.
+func Live(c *gin.Context) {
+	c.JSON(http.StatusOK, services.HealthService.Live())
+}
+
+// Ready is the readiness check, answering 503 while a critical dependency is down or the
+// application is shutting down.
+func Ready(c *gin.Context) {
+	report := services.HealthService.Ready(c.Request.Context())
+
+	status := http.StatusServiceUnavailable
+	if report.Status == health.StatusUp || report.Status == health.StatusDegraded {
+		status = http.StatusOK
+	}
+	c.JSON(status, report)
+}
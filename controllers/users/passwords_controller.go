@@ -0,0 +1,67 @@
+package users
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/migueloli/bookstore_users-api/domain/users"
+	"github.com/migueloli/bookstore_users-api/services"
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+// ForgotPassword is the entry point for requesting a password reset token.
+func ForgotPassword(c *gin.Context) {
+	var request users.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		restErr := resterrors.NewBadRequestError("Invalid JSON body.")
+		c.JSON(restErr.Status, restErr)
+		return
+	}
+
+	if err := services.PasswordsService.ForgotPassword(request); err != nil {
+		c.JSON(err.Status, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, map[string]string{"status": "If the e-mail is registered a password reset token was sent."})
+}
+
+// ResetPassword is the entry point for defining a new password with a reset token.
+func ResetPassword(c *gin.Context) {
+	var request users.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		restErr := resterrors.NewBadRequestError("Invalid JSON body.")
+		c.JSON(restErr.Status, restErr)
+		return
+	}
+
+	if err := services.PasswordsService.ResetPassword(request, getAuditActor(c)); err != nil {
+		c.JSON(err.Status, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]string{"status": "Password updated successfully."})
+}
+
+// ChangePassword is the entry point for the owner replacing the password of the user by id.
+func ChangePassword(c *gin.Context) {
+	userID, idErr := getUserID(c.Param("user_id"))
+	if idErr != nil {
+		c.JSON(idErr.Status, idErr)
+		return
+	}
+
+	var request users.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		restErr := resterrors.NewBadRequestError("Invalid JSON body.")
+		c.JSON(restErr.Status, restErr)
+		return
+	}
+
+	if err := services.PasswordsService.ChangePassword(userID, request, getAuditActor(c)); err != nil {
+		c.JSON(err.Status, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]string{"status": "Password updated successfully."})
+}
@@ -0,0 +1,59 @@
+package users
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/migueloli/bookstore_users-api/services"
+)
+
+// GetRoles is the entry point for listing the roles of the user by id.
+func GetRoles(c *gin.Context) {
+	userID, idErr := getUserID(c.Param("user_id"))
+	if idErr != nil {
+		c.JSON(idErr.Status, idErr)
+		return
+	}
+
+	roles, err := services.AuthorizationService.GetRoles(userID)
+	if err != nil {
+		c.JSON(err.Status, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+// GrantRole is the entry point for granting a role to the user by id.
+func GrantRole(c *gin.Context) {
+	userID, idErr := getUserID(c.Param("user_id"))
+	if idErr != nil {
+		c.JSON(idErr.Status, idErr)
+		return
+	}
+
+	roles, err := services.AuthorizationService.GrantRole(userID, c.Param("role"), getAuditActor(c))
+	if err != nil {
+		c.JSON(err.Status, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+// RevokeRole is the entry point for revoking a role from the user by id.
+func RevokeRole(c *gin.Context) {
+	userID, idErr := getUserID(c.Param("user_id"))
+	if idErr != nil {
+		c.JSON(idErr.Status, idErr)
+		return
+	}
+
+	roles, err := services.AuthorizationService.RevokeRole(userID, c.Param("role"), getAuditActor(c))
+	if err != nil {
+		c.JSON(err.Status, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
@@ -0,0 +1,52 @@
+package users
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/migueloli/bookstore_users-api/domain/users"
+	"github.com/migueloli/bookstore_users-api/logger"
+	"github.com/migueloli/bookstore_users-api/services"
+)
+
+var transferContentTypes = map[string]string{
+	users.TransferFormatCSV:    "text/csv; charset=utf-8",
+	users.TransferFormatNDJSON: "application/x-ndjson",
+}
+
+// getTransferFormat reads the format param of the export and import, CSV by default.
+func getTransferFormat(c *gin.Context) string {
+	return c.DefaultQuery("format", users.TransferFormatCSV)
+}
+
+// Export is the entry point for streaming the users matching the search params as CSV or NDJSON.
+func Export(c *gin.Context) {
+	format := getTransferFormat(c)
+	c.Header("Content-Type", transferContentTypes[format])
+	c.Header("Content-Disposition", "attachment; filename=users."+format)
+
+	if err := services.TransferService.ExportUsers(getSearchFilters(c), format, c.Writer); err != nil {
+		// Once the export started the status is already sent, the client sees a truncated file.
+		if c.Writer.Written() {
+			logger.Error("Error when trying to stream the users export.", errors.New(err.Message))
+			return
+		}
+
+		c.Writer.Header().Del("Content-Type")
+		c.Writer.Header().Del("Content-Disposition")
+		c.JSON(err.Status, err)
+	}
+}
+
+// Import is the entry point for creating the users of the CSV or NDJSON body, reporting the lines
+// that fail. The dry_run param only validates them.
+func Import(c *gin.Context) {
+	result, err := services.TransferService.ImportUsers(c.Request.Body, getTransferFormat(c), c.Query("dry_run") == "true", getAuditActor(c))
+	if err != nil {
+		c.JSON(err.Status, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -1,12 +1,14 @@
 package users
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/migueloli/bookstore_oauth-go/oauth"
 	"github.com/migueloli/bookstore_users-api/domain/users"
+	"github.com/migueloli/bookstore_users-api/middlewares"
 	"github.com/migueloli/bookstore_users-api/services"
 	"github.com/migueloli/bookstore_utils-go/resterrors"
 )
@@ -20,6 +22,39 @@ func getUserID(userIDParam string) (int64, *resterrors.RestErr) {
 	return userID, nil
 }
 
+func getQueryInt(c *gin.Context, name string) (int, *resterrors.RestErr) {
+	value := c.Query(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, resterrors.NewBadRequestError(fmt.Sprintf("Query param %s should be a number.", name))
+	}
+
+	return number, nil
+}
+
+// getAuditActor returns who is calling, recorded by the audit trail of the mutations.
+func getAuditActor(c *gin.Context) users.AuditActor {
+	return users.AuditActor{
+		UserID:    oauth.GetCallerID(c.Request),
+		ClientIP:  c.ClientIP(),
+		RequestID: middlewares.GetRequestID(c),
+	}
+}
+
+// setRetryAfter exposes the users.RetryAfter cause of the error as the Retry-After header.
+func setRetryAfter(c *gin.Context, err *resterrors.RestErr) {
+	for _, cause := range err.Causes {
+		if retry, ok := cause.(users.RetryAfter); ok {
+			c.Header("Retry-After", strconv.FormatInt(retry.RetryAfterSeconds, 10))
+			return
+		}
+	}
+}
+
 // Create is the entry point for creating an user.
 func Create(c *gin.Context) {
 	var user users.User
@@ -29,28 +64,36 @@ func Create(c *gin.Context) {
 		return
 	}
 
-	result, saveErr := services.UsersService.CreateUser(user)
+	result, saveErr := services.UsersService.CreateUser(user, getAuditActor(c))
 	if saveErr != nil {
 		c.JSON(saveErr.Status, saveErr)
 		return
 	}
 
+	c.Header("ETag", result.ETag())
 	c.JSON(http.StatusCreated, result.Marshall(c.GetHeader("X-Public") == "true"))
 }
 
-// Get is the entry point for getting the user by id.
-func Get(c *gin.Context) {
-	if err := oauth.AuthenticateRequest(c.Request); err != nil {
-		c.JSON(err.Status, err)
+// CreateBatch is the entry point for creating a batch of users, answering the outcome of each one.
+func CreateBatch(c *gin.Context) {
+	var request users.BatchCreateRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		restErr := resterrors.NewBadRequestError("Invalid JSON body.")
+		c.JSON(restErr.Status, restErr)
 		return
 	}
 
-	if callerID := oauth.GetCallerID(c.Request); callerID == 0 {
-		err := resterrors.RestErr{}
+	result, err := services.UsersService.CreateUsers(request, getAuditActor(c))
+	if err != nil {
 		c.JSON(err.Status, err)
 		return
 	}
 
+	c.JSON(http.StatusMultiStatus, result.Marshall(c.GetHeader("X-Public") == "true"))
+}
+
+// Get is the entry point for getting the user by id.
+func Get(c *gin.Context) {
 	userID, idErr := getUserID(c.Param("user_id"))
 	if idErr != nil {
 		c.JSON(idErr.Status, idErr)
@@ -63,12 +106,42 @@ func Get(c *gin.Context) {
 		return
 	}
 
-	if oauth.GetCallerID(c.Request) == user.ID {
-		c.JSON(http.StatusOK, user.Marshall(false))
+	isPublic := false
+	if middlewares.GetCallerID(c) != user.ID && !middlewares.HasPermission(c, users.PermissionUsersReadPrivate) {
+		// Banned users are only visible to themselves and to the staff.
+		if user.Status == users.StatusBanned {
+			restErr := resterrors.NewNotFoundError(fmt.Sprintf("User %d not found.", user.ID))
+			c.JSON(restErr.Status, restErr)
+			return
+		}
+
+		isPublic = oauth.IsPublic(c.Request)
+	}
+
+	c.Header("ETag", user.ETag())
+	if user.MatchesIfNoneMatch(c.GetHeader("If-None-Match")) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.JSON(http.StatusOK, user.Marshall(isPublic))
+}
+
+// GetBatch is the entry point for getting the users by the comma separated ids param.
+func GetBatch(c *gin.Context) {
+	userIDs, idsErr := users.ParseUserIDs(c.Query("ids"))
+	if idsErr != nil {
+		c.JSON(idsErr.Status, idsErr)
+		return
+	}
+
+	result, err := services.UsersService.GetUsers(userIDs)
+	if err != nil {
+		c.JSON(err.Status, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, user.Marshall(oauth.IsPublic(c.Request)))
+	c.JSON(http.StatusOK, result.Marshall(c.GetHeader("X-Public") == "true"))
 }
 
 // Update is the entry point for updating the user by id.
@@ -86,16 +159,47 @@ func Update(c *gin.Context) {
 		return
 	}
 
-	isPartial := c.Request.Method == http.MethodPatch
-
 	user.ID = userID
 
-	result, err := services.UsersService.UpdateUser(isPartial, user)
+	result, err := services.UsersService.UpdateUser(user, c.GetHeader("If-Match"), getAuditActor(c))
+	if err != nil {
+		c.JSON(err.Status, err)
+		return
+	}
+
+	c.Header("ETag", result.ETag())
+	c.JSON(http.StatusOK, result.Marshall(c.GetHeader("X-Public") == "true"))
+}
+
+// Patch is the entry point for partially updating the user by id with a JSON Merge Patch, also
+// accepted as plain JSON, or a JSON Patch.
+func Patch(c *gin.Context) {
+	userID, idErr := getUserID(c.Param("user_id"))
+	if idErr != nil {
+		c.JSON(idErr.Status, idErr)
+		return
+	}
+
+	body, readErr := c.GetRawData()
+	if readErr != nil {
+		restErr := resterrors.NewBadRequestError("Invalid request body.")
+		c.JSON(restErr.Status, restErr)
+		return
+	}
+
+	patch, patchErr := users.NewPatch(c.ContentType(), body)
+	if patchErr != nil {
+		c.JSON(patchErr.Status, patchErr)
+		return
+	}
+
+	result, err := services.UsersService.PatchUser(userID, patch, c.GetHeader("If-Match"), getAuditActor(c))
 	if err != nil {
 		c.JSON(err.Status, err)
 		return
 	}
 
+	c.Header("ETag", result.ETag())
 	c.JSON(http.StatusOK, result.Marshall(c.GetHeader("X-Public") == "true"))
 }
 
@@ -107,7 +211,7 @@ func Delete(c *gin.Context) {
 		return
 	}
 
-	if err := services.UsersService.DeleteUser(userID); err != nil {
+	if err := services.UsersService.DeleteUser(userID, c.GetHeader("If-Match"), getAuditActor(c)); err != nil {
 		c.JSON(err.Status, err)
 		return
 	}
@@ -115,33 +219,150 @@ func Delete(c *gin.Context) {
 	c.JSON(http.StatusOK, map[string]string{"status": "Deleted successfully."})
 }
 
-// Search is the entry point for searching a list of users by params.
+// Restore is the entry point for bringing back the deleted user by id.
+func Restore(c *gin.Context) {
+	userID, idErr := getUserID(c.Param("user_id"))
+	if idErr != nil {
+		c.JSON(idErr.Status, idErr)
+		return
+	}
+
+	user, err := services.UsersService.RestoreUser(userID, getAuditActor(c))
+	if err != nil {
+		c.JSON(err.Status, err)
+		return
+	}
+
+	c.Header("ETag", user.ETag())
+	c.JSON(http.StatusOK, user.Marshall(false))
+}
+
+// ChangeStatus returns the entry point for applying the status operation to the user by id.
+func ChangeStatus(operation string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, idErr := getUserID(c.Param("user_id"))
+		if idErr != nil {
+			c.JSON(idErr.Status, idErr)
+			return
+		}
+
+		var request users.StatusChangeRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			restErr := resterrors.NewBadRequestError("Invalid JSON body.")
+			c.JSON(restErr.Status, restErr)
+			return
+		}
+
+		user, err := services.UsersService.ChangeStatus(userID, operation, request, getAuditActor(c))
+		if err != nil {
+			c.JSON(err.Status, err)
+			return
+		}
+
+		c.Header("ETag", user.ETag())
+		c.JSON(http.StatusOK, user.Marshall(false))
+	}
+}
+
+// getSearchFilters reads the filters of the user search from the query params.
+func getSearchFilters(c *gin.Context) users.SearchRequest {
+	return users.SearchRequest{
+		Query:       c.Query("q"),
+		Status:      c.Query("status"),
+		EmailDomain: c.Query("email_domain"),
+		NamePrefix:  c.Query("name_prefix"),
+		CreatedFrom: c.Query("created_from"),
+		CreatedTo:   c.Query("created_to"),
+	}
+}
+
+// Search is the entry point for searching a page of users by params.
 func Search(c *gin.Context) {
-	status := c.Query("status")
+	request := getSearchFilters(c)
+	request.Sort = c.Query("sort")
+	request.Order = c.Query("order")
+	request.Cursor = c.Query("cursor")
 
-	users, err := services.UsersService.SearchUser(status)
+	var pageErr *resterrors.RestErr
+	if request.Limit, pageErr = getQueryInt(c, "limit"); pageErr != nil {
+		c.JSON(pageErr.Status, pageErr)
+		return
+	}
+	if request.Offset, pageErr = getQueryInt(c, "offset"); pageErr != nil {
+		c.JSON(pageErr.Status, pageErr)
+		return
+	}
+
+	result, err := services.UsersService.SearchUser(request)
 	if err != nil {
 		c.JSON(err.Status, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, users.Marshall(c.GetHeader("X-Public") == "true"))
+	c.JSON(http.StatusOK, result.Marshall(c.GetHeader("X-Public") == "true"))
 }
 
 // Login is the entry point for login with a email and password.
 func Login(c *gin.Context) {
 	request := users.UserLoginRequest{}
-	if err := c.ShouldBindJSON(request); err != nil {
+	if err := c.ShouldBindJSON(&request); err != nil {
 		restErr := resterrors.NewBadRequestError("Invalid JSON body.")
 		c.JSON(restErr.Status, restErr)
 		return
 	}
 
-	user, err := services.UsersService.LoginUser(request)
+	request.ClientIP = c.ClientIP()
+
+	user, err := services.UsersService.LoginUser(request, getAuditActor(c))
 	if err != nil {
+		setRetryAfter(c, err)
 		c.JSON(err.Status, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, user.Marshall(c.GetHeader("X-Public") == "true"))
 }
+
+// GetAudit is the entry point for listing a page of the audit trail of the user by id, newest first.
+func GetAudit(c *gin.Context) {
+	userID, idErr := getUserID(c.Param("user_id"))
+	if idErr != nil {
+		c.JSON(idErr.Status, idErr)
+		return
+	}
+
+	request := users.AuditRequest{UserID: userID}
+	var pageErr *resterrors.RestErr
+	if request.Limit, pageErr = getQueryInt(c, "limit"); pageErr != nil {
+		c.JSON(pageErr.Status, pageErr)
+		return
+	}
+	if request.Offset, pageErr = getQueryInt(c, "offset"); pageErr != nil {
+		c.JSON(pageErr.Status, pageErr)
+		return
+	}
+
+	result, err := services.UsersService.GetAudit(request)
+	if err != nil {
+		c.JSON(err.Status, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Unlock is the entry point for clearing the login lockout of the user by id.
+func Unlock(c *gin.Context) {
+	userID, idErr := getUserID(c.Param("user_id"))
+	if idErr != nil {
+		c.JSON(idErr.Status, idErr)
+		return
+	}
+
+	if err := services.UsersService.UnlockUser(userID, getAuditActor(c)); err != nil {
+		c.JSON(err.Status, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]string{"status": "Unlocked successfully."})
+}
@@ -0,0 +1,37 @@
+package users
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/migueloli/bookstore_users-api/domain/users"
+	"github.com/migueloli/bookstore_users-api/services"
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+// VerifyEmail is the entry point for verifying the user e-mail with a token.
+func VerifyEmail(c *gin.Context) {
+	if err := services.UsersService.VerifyEmail(c.Query("token"), getAuditActor(c)); err != nil {
+		c.JSON(err.Status, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, map[string]string{"status": "E-mail verified successfully."})
+}
+
+// ResendVerification is the entry point for requesting a new e-mail verification token.
+func ResendVerification(c *gin.Context) {
+	var request users.ResendVerificationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		restErr := resterrors.NewBadRequestError("Invalid JSON body.")
+		c.JSON(restErr.Status, restErr)
+		return
+	}
+
+	if err := services.UsersService.ResendVerification(request); err != nil {
+		c.JSON(err.Status, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, map[string]string{"status": "If the e-mail is pending verification a new token was sent."})
+}
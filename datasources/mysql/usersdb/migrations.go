@@ -0,0 +1,297 @@
+package usersdb
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/migueloli/bookstore_users-api/logger"
+)
+
+const (
+	queryCreateMigrationsTable = "CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL, name VARCHAR(255) NOT NULL, applied_at DATETIME NOT NULL, PRIMARY KEY (version)) ENGINE = InnoDB;"
+	queryGetAppliedMigrations  = "SELECT version, applied_at FROM schema_migrations ORDER BY version;"
+	queryInsertMigration       = "INSERT INTO schema_migrations(version, name, applied_at) VALUES (?, ?, ?);"
+	queryDeleteMigration       = "DELETE FROM schema_migrations WHERE version = ?;"
+	queryGetMigrationLock      = "SELECT GET_LOCK(?, ?);"
+	queryReleaseMigrationLock  = "SELECT RELEASE_LOCK(?);"
+
+	// migrationLockName is the MySQL named lock held while migrating, so the instances starting
+	// together don't apply the same migration twice.
+	migrationLockName = "bookstore_users_schema_migrations"
+	// migrationLockTimeout is how long, in seconds, an instance waits for another one to migrate.
+	migrationLockTimeout = 300
+
+	migrationsDir = "migrations"
+	upSuffix      = ".up.sql"
+	downSuffix    = ".down.sql"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration is a versioned change of the users schema, loaded from the embedded files
+// named as <version>_<name>.up.sql and <version>_<name>.down.sql.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus is a migration with the information of when it was applied.
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt string
+}
+
+// LoadMigrations reads the embedded migrations ordered by version.
+func LoadMigrations() ([]Migration, error) {
+	entries, err := migrationFiles.ReadDir(migrationsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		fileName := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, upSuffix):
+			direction = upSuffix
+		case strings.HasSuffix(fileName, downSuffix):
+			direction = downSuffix
+		default:
+			return nil, fmt.Errorf("invalid migration file name %s", fileName)
+		}
+
+		parts := strings.SplitN(strings.TrimSuffix(fileName, direction), "_", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid migration file name %s", fileName)
+		}
+
+		version, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s", fileName)
+		}
+
+		content, err := migrationFiles.ReadFile(path.Join(migrationsDir, fileName))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, exists := byVersion[version]
+		if !exists {
+			migration = &Migration{Version: version, Name: parts[1]}
+			byVersion[version] = migration
+		}
+		if migration.Name != parts[1] {
+			return nil, fmt.Errorf("migration version %d used by %s and %s", version, migration.Name, parts[1])
+		}
+
+		if direction == upSuffix {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both up and down files", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// MigrateUp applies every pending migration in order, returning how many were applied.
+func MigrateUp(db *sql.DB) (applied int, err error) {
+	conn, release, err := lockMigrations(db)
+	if err != nil {
+		return 0, err
+	}
+
+	defer func() {
+		if releaseErr := release(); releaseErr != nil && err == nil {
+			err = releaseErr
+		}
+	}()
+
+	status, err := getMigrationStatus(conn)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, migration := range status {
+		if migration.Applied {
+			continue
+		}
+
+		logger.Info(fmt.Sprintf("Applying migration %d_%s.", migration.Version, migration.Name))
+		if err := execStatements(conn, migration.Up); err != nil {
+			return applied, fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+
+		if _, err := conn.ExecContext(context.Background(), queryInsertMigration, migration.Version, migration.Name, time.Now().UTC().Format("2006-01-02 15:04:05")); err != nil {
+			return applied, err
+		}
+		applied++
+	}
+
+	return applied, nil
+}
+
+// MigrateDown reverts the last steps applied migrations, returning how many were reverted.
+func MigrateDown(db *sql.DB, steps int) (reverted int, err error) {
+	conn, release, err := lockMigrations(db)
+	if err != nil {
+		return 0, err
+	}
+
+	defer func() {
+		if releaseErr := release(); releaseErr != nil && err == nil {
+			err = releaseErr
+		}
+	}()
+
+	status, err := getMigrationStatus(conn)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := len(status) - 1; i >= 0 && reverted < steps; i-- {
+		migration := status[i]
+		if !migration.Applied {
+			continue
+		}
+
+		logger.Info(fmt.Sprintf("Reverting migration %d_%s.", migration.Version, migration.Name))
+		if err := execStatements(conn, migration.Down); err != nil {
+			return reverted, fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+
+		if _, err := conn.ExecContext(context.Background(), queryDeleteMigration, migration.Version); err != nil {
+			return reverted, err
+		}
+		reverted++
+	}
+
+	return reverted, nil
+}
+
+// lockMigrations waits for the migration lock on a dedicated connection, as MySQL named locks
+// belong to the session holding them, and returns it with the function releasing it. The whole
+// migration runs on that connection, so it needs no other one from the pool.
+func lockMigrations(db *sql.DB) (*sql.Conn, func() error, error) {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, queryGetMigrationLock, migrationLockName, migrationLockTimeout).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if !acquired.Valid || acquired.Int64 != 1 {
+		conn.Close()
+		return nil, nil, errors.New("timeout waiting for another instance to apply the migrations")
+	}
+
+	return conn, func() error {
+		defer conn.Close()
+
+		_, err := conn.ExecContext(ctx, queryReleaseMigrationLock, migrationLockName)
+		return err
+	}, nil
+}
+
+// GetMigrationStatus returns every embedded migration with its applied state.
+func GetMigrationStatus(db *sql.DB) ([]MigrationStatus, error) {
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	return getMigrationStatus(conn)
+}
+
+func getMigrationStatus(conn *sql.Conn) ([]MigrationStatus, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	if _, err := conn.ExecContext(ctx, queryCreateMigrationsTable); err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.QueryContext(ctx, queryGetAppliedMigrations)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	appliedAt := make(map[int64]string)
+	for rows.Next() {
+		var version int64
+		var at string
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		appliedAt[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, len(migrations))
+	for i, migration := range migrations {
+		at, applied := appliedAt[migration.Version]
+		status[i] = MigrationStatus{
+			Migration: migration,
+			Applied:   applied,
+			AppliedAt: at,
+		}
+	}
+
+	return status, nil
+}
+
+// execStatements runs each statement of the script, as the driver doesn't accept multiple
+// statements in a single call. Statements are split on the semicolon ending a line.
+func execStatements(conn *sql.Conn, script string) error {
+	for _, statement := range strings.Split(script, ";\n") {
+		statement = strings.TrimSpace(statement)
+		if statement == "" {
+			continue
+		}
+
+		if _, err := conn.ExecContext(context.Background(), statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS users (
+    id BIGINT NOT NULL AUTO_INCREMENT,
+    first_name VARCHAR(45) NOT NULL DEFAULT '',
+    last_name VARCHAR(45) NOT NULL DEFAULT '',
+    email VARCHAR(255) NOT NULL,
+    date_created DATETIME NOT NULL,
+    status VARCHAR(45) NOT NULL,
+    password VARCHAR(255) NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE INDEX users_email_unique (email),
+    INDEX users_status_idx (status)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS email_verification_tokens (
+    id BIGINT NOT NULL AUTO_INCREMENT,
+    user_id BIGINT NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    date_created DATETIME NOT NULL,
+    date_expires DATETIME NOT NULL,
+    date_used DATETIME NULL,
+    PRIMARY KEY (id),
+    UNIQUE INDEX email_verification_tokens_hash_unique (token_hash),
+    INDEX email_verification_tokens_user_idx (user_id, date_created),
+    CONSTRAINT email_verification_tokens_user_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
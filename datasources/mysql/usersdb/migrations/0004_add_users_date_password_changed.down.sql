@@ -0,0 +1 @@
+ALTER TABLE users DROP COLUMN date_password_changed;
@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN date_password_changed DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP AFTER password;
+UPDATE users SET date_password_changed = date_created;
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id BIGINT NOT NULL,
+    role VARCHAR(32) NOT NULL,
+    date_created DATETIME NOT NULL,
+    PRIMARY KEY (user_id, role),
+    CONSTRAINT user_roles_user_fk FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1 @@
+ALTER TABLE users DROP INDEX users_text_search;
@@ -0,0 +1 @@
+ALTER TABLE users ADD FULLTEXT INDEX users_text_search (first_name, last_name, email);
@@ -0,0 +1,5 @@
+DELETE FROM users WHERE date_deleted IS NOT NULL;
+ALTER TABLE users
+    DROP INDEX users_date_deleted_idx,
+    DROP COLUMN status_before_delete,
+    DROP COLUMN date_deleted;
@@ -0,0 +1,4 @@
+ALTER TABLE users
+    ADD COLUMN date_deleted DATETIME NULL AFTER date_password_changed,
+    ADD COLUMN status_before_delete VARCHAR(45) NULL AFTER date_deleted,
+    ADD INDEX users_date_deleted_idx (date_deleted);
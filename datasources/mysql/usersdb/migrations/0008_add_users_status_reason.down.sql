@@ -0,0 +1,3 @@
+ALTER TABLE users
+    DROP COLUMN date_status_changed,
+    DROP COLUMN status_reason;
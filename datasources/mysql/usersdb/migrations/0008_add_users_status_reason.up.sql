@@ -0,0 +1,4 @@
+ALTER TABLE users
+    ADD COLUMN status_reason VARCHAR(255) NOT NULL DEFAULT '' AFTER status,
+    ADD COLUMN date_status_changed DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP AFTER status_reason;
+UPDATE users SET date_status_changed = date_created;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key_hash CHAR(64) NOT NULL,
+    fingerprint CHAR(64) NOT NULL,
+    response_status INT NOT NULL DEFAULT 0,
+    response_headers TEXT NOT NULL,
+    response_body MEDIUMBLOB NOT NULL,
+    date_created DATETIME NOT NULL,
+    date_expires DATETIME NOT NULL,
+    PRIMARY KEY (key_hash),
+    INDEX idempotency_keys_expires_idx (date_expires)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
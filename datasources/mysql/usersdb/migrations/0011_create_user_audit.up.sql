@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS user_audit (
+    id BIGINT NOT NULL AUTO_INCREMENT,
+    user_id BIGINT NOT NULL,
+    actor_id BIGINT NOT NULL DEFAULT 0,
+    action VARCHAR(45) NOT NULL,
+    changes JSON NOT NULL,
+    client_ip VARCHAR(45) NOT NULL DEFAULT '',
+    request_id VARCHAR(128) NOT NULL DEFAULT '',
+    date_created DATETIME NOT NULL,
+    PRIMARY KEY (id),
+    INDEX user_audit_user_idx (user_id, id)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4;
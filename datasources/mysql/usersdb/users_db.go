@@ -1,49 +1,126 @@
 package usersdb
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
-	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/migueloli/bookstore_users-api/config"
+	"github.com/migueloli/bookstore_users-api/logger"
 
 	// Driver imported for mysql connection.
 	_ "github.com/go-sql-driver/mysql"
 )
 
-const (
-	mysqlUsersUsername = "mysql_users_username"
-	mysqlUsersPassword = "mysql_users_password"
-	mysqlUsersHost     = "mysql_users_host"
-	mysqlUsersSchema   = "mysql_users_schema"
-)
-
 // Client is a database connection.
 var (
 	Client *sql.DB
 
-	username = os.Getenv(mysqlUsersUsername)
-	password = os.Getenv(mysqlUsersPassword)
-	host     = os.Getenv(mysqlUsersHost)
-	schema   = os.Getenv(mysqlUsersSchema)
+	// connected is set once Connect reaches the database.
+	connected int32
 )
 
-func init() {
+// New creates the Client with the pool settings of the configuration, without connecting to the
+// database yet.
+func New(cfg config.DatabaseConfig) (*sql.DB, error) {
 	datasourceName := fmt.Sprintf(
 		"%s:%s@tcp(%s)/%s?charset=utf8",
-		username,
-		password,
-		host,
-		schema,
+		cfg.Username,
+		cfg.Password,
+		cfg.Host,
+		cfg.Schema,
 	)
 
-	var err error
-	Client, err = sql.Open("mysql", datasourceName)
+	client, err := sql.Open("mysql", datasourceName)
+	if err != nil {
+		return nil, err
+	}
+
+	client.SetMaxOpenConns(cfg.MaxOpenConns)
+	client.SetMaxIdleConns(cfg.MaxIdleConns)
+	client.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	client.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	Client = client
+
+	return client, nil
+}
+
+// Open creates the Client with New and waits for the database with Connect.
+func Open(ctx context.Context, cfg config.DatabaseConfig) (*sql.DB, error) {
+	client, err := New(cfg)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	if err = Client.Ping(); err != nil {
-		panic(err)
+	if err := Connect(ctx, client, cfg); err != nil {
+		client.Close()
+		return nil, err
 	}
-	log.Println("Database successfully configured.")
+
+	return client, nil
+}
+
+// Connect pings the database until it answers, up to ConnectAttempts times or forever when it is
+// 0, doubling the wait between the attempts from ConnectBackoff up to ConnectMaxBackoff. When
+// AutoMigrate is enabled the pending migrations are applied once it is reachable.
+func Connect(ctx context.Context, client *sql.DB, cfg config.DatabaseConfig) error {
+	backoff := cfg.ConnectBackoff
+	for attempt := 1; ; attempt++ {
+		err := ping(ctx, client, cfg.ConnectTimeout)
+		if err == nil {
+			break
+		}
+		if cfg.ConnectAttempts > 0 && attempt >= cfg.ConnectAttempts {
+			return fmt.Errorf("database unreachable after %d attempts: %w", attempt, err)
+		}
+
+		logger.Error(fmt.Sprintf("Database unreachable on attempt %d, retrying in %s.", attempt, backoff), err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > cfg.ConnectMaxBackoff {
+			backoff = cfg.ConnectMaxBackoff
+		}
+	}
+	logger.Info("Database successfully configured.")
+
+	if cfg.AutoMigrate {
+		applied, err := MigrateUp(client)
+		if err != nil {
+			return err
+		}
+		logger.Info(fmt.Sprintf("%d database migrations applied.", applied))
+	}
+
+	atomic.StoreInt32(&connected, 1)
+	return nil
+}
+
+// Connected tells whether Connect already reached the database. It doesn't mean the database is
+// still reachable, only that the startup is done.
+func Connected() bool {
+	return atomic.LoadInt32(&connected) == 1
+}
+
+func ping(ctx context.Context, client *sql.DB, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	return client.PingContext(ctx)
+}
+
+// Check is the readiness check of the users database, down until Connect is done and whenever
+// the Client can't ping it.
+func Check(ctx context.Context) error {
+	if !Connected() {
+		return errors.New("database still connecting")
+	}
+
+	return Client.PingContext(ctx)
 }
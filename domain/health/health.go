@@ -0,0 +1,125 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Statuses of the checks and of the report.
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+	// StatusDegraded is the report status when only checks that aren't critical are down.
+	StatusDegraded = "degraded"
+	// StatusShuttingDown is the report status once the application started to shut down.
+	StatusShuttingDown = "shutting_down"
+)
+
+// Checker checks a dependency of the application, returning why it can't be used.
+type Checker interface {
+	Check(context.Context) error
+}
+
+// CheckerFunc is a function used as a Checker.
+type CheckerFunc func(context.Context) error
+
+// Check calls the function.
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// CheckResult is the outcome of a check in the report.
+type CheckResult struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	Critical  bool    `json:"critical"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the status of the application and of each of its checks.
+type Report struct {
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+type registration struct {
+	name     string
+	critical bool
+	checker  Checker
+}
+
+// Registry holds the checks of the dependencies, run together by Run. It is safe for concurrent
+// use, so the checks can be registered as the dependencies are set up.
+type Registry struct {
+	mu      sync.RWMutex
+	timeout time.Duration
+	checks  []registration
+}
+
+// NewRegistry creates an empty Registry giving each check up to timeout to answer.
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{timeout: timeout}
+}
+
+// Register adds the check of the dependency. A critical check down makes the report down, the other
+// ones only degrade it.
+func (r *Registry) Register(name string, critical bool, checker Checker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checks = append(r.checks, registration{name: name, critical: critical, checker: checker})
+}
+
+// Run executes every check concurrently, reporting them in the registration order.
+func (r *Registry) Run(ctx context.Context) *Report {
+	r.mu.RLock()
+	checks := make([]registration, len(r.checks))
+	copy(checks, r.checks)
+	r.mu.RUnlock()
+
+	report := &Report{Status: StatusUp, Checks: make([]CheckResult, len(checks))}
+	var wg sync.WaitGroup
+	for index, check := range checks {
+		wg.Add(1)
+		go func(index int, check registration) {
+			defer wg.Done()
+			report.Checks[index] = r.run(ctx, check)
+		}(index, check)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status == StatusUp {
+			continue
+		}
+		if result.Critical {
+			report.Status = StatusDown
+			break
+		}
+		report.Status = StatusDegraded
+	}
+
+	return report
+}
+
+func (r *Registry) run(ctx context.Context, check registration) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.checker.Check(ctx)
+	result := CheckResult{
+		Name:      check.name,
+		Status:    StatusUp,
+		Critical:  check.critical,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+
+	return result
+}
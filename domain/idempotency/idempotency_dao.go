@@ -0,0 +1,191 @@
+package idempotency
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/migueloli/bookstore_users-api/logger"
+	"github.com/migueloli/bookstore_users-api/utils/mysqlutils"
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+const (
+	queryInsertRecord   = "INSERT INTO idempotency_keys(key_hash, fingerprint, response_status, response_headers, response_body, date_created, date_expires) VALUES (?, ?, 0, '{}', '', ?, ?);"
+	queryGetRecord      = "SELECT fingerprint, response_status, response_headers, response_body, date_created, date_expires FROM idempotency_keys WHERE key_hash = ?;"
+	queryDeleteExpired  = "DELETE FROM idempotency_keys WHERE key_hash = ? AND date_expires <= ?;"
+	queryCompleteRecord = "UPDATE idempotency_keys SET response_status = ?, response_headers = ?, response_body = ? WHERE key_hash = ?;"
+	queryReleaseRecord  = "DELETE FROM idempotency_keys WHERE key_hash = ? AND response_status = 0;"
+	queryPurgeRecords   = "DELETE FROM idempotency_keys WHERE date_expires < ?;"
+)
+
+type mysqlRepository struct {
+	client *sql.DB
+}
+
+// NewMySQLRepository creates the Repository backed by the given MySQL client.
+func NewMySQLRepository(client *sql.DB) Repository {
+	return &mysqlRepository{client: client}
+}
+
+// Reserve the record in the database or return the one holding the key. The primary key on the
+// key hash guarantees that concurrent requests can't both reserve it.
+func (r *mysqlRepository) Reserve(record *Record, now string) (*Record, *resterrors.RestErr) {
+	inserted, err := r.insert(record)
+	if err != nil || inserted {
+		return nil, err
+	}
+
+	current, err := r.get(record.KeyHash)
+	if err != nil {
+		return nil, err
+	}
+	if current != nil && current.DateExpires > now {
+		return current, nil
+	}
+
+	if err := r.deleteExpired(record.KeyHash, now); err != nil {
+		return nil, err
+	}
+
+	if inserted, err = r.insert(record); err != nil || inserted {
+		return nil, err
+	}
+
+	// Another request reserved the key between the deletion and the insert.
+	return r.get(record.KeyHash)
+}
+
+// Complete the record in the database or return the RestErr.
+func (r *mysqlRepository) Complete(record *Record) *resterrors.RestErr {
+	headers, err := json.Marshal(record.Headers)
+	if err != nil {
+		logger.Error("Error when trying to encode the idempotency record headers.", err)
+		return resterrors.NewInternalServerError("Error when trying to encode the idempotency record headers.", errors.New("encoding error"))
+	}
+
+	stmt, err := r.client.Prepare(queryCompleteRecord)
+	if err != nil {
+		logger.Error("Error when trying to prepare the complete idempotency record statement.", err)
+		return resterrors.NewInternalServerError("Error when trying to prepare the complete idempotency record statement.", errors.New("database error"))
+	}
+
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(record.Status, string(headers), record.Body, record.KeyHash); err != nil {
+		logger.Error("Error when trying to complete idempotency record.", err)
+		return resterrors.NewInternalServerError("Error when trying to complete idempotency record.", errors.New("database error"))
+	}
+
+	return nil
+}
+
+// Release the record in progress from the database or return the RestErr.
+func (r *mysqlRepository) Release(keyHash string) *resterrors.RestErr {
+	stmt, err := r.client.Prepare(queryReleaseRecord)
+	if err != nil {
+		logger.Error("Error when trying to prepare the release idempotency record statement.", err)
+		return resterrors.NewInternalServerError("Error when trying to prepare the release idempotency record statement.", errors.New("database error"))
+	}
+
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(keyHash); err != nil {
+		logger.Error("Error when trying to release idempotency record.", err)
+		return resterrors.NewInternalServerError("Error when trying to release idempotency record.", errors.New("database error"))
+	}
+
+	return nil
+}
+
+// Purge the records expired before the date from the database or return the RestErr.
+func (r *mysqlRepository) Purge(before string) (int64, *resterrors.RestErr) {
+	stmt, err := r.client.Prepare(queryPurgeRecords)
+	if err != nil {
+		logger.Error("Error when trying to prepare the purge idempotency records statement.", err)
+		return 0, resterrors.NewInternalServerError("Error when trying to prepare the purge idempotency records statement.", errors.New("database error"))
+	}
+
+	defer stmt.Close()
+
+	purgeResult, err := stmt.Exec(before)
+	if err != nil {
+		logger.Error("Error when trying to purge idempotency records.", err)
+		return 0, resterrors.NewInternalServerError("Error when trying to purge idempotency records.", errors.New("database error"))
+	}
+
+	purged, err := purgeResult.RowsAffected()
+	if err != nil {
+		logger.Error("Error when trying to get the purged idempotency records count.", err)
+		return 0, resterrors.NewInternalServerError("Error when trying to get the purged idempotency records count.", errors.New("database error"))
+	}
+
+	return purged, nil
+}
+
+// insert the record, informing false when the key is already taken.
+func (r *mysqlRepository) insert(record *Record) (bool, *resterrors.RestErr) {
+	stmt, err := r.client.Prepare(queryInsertRecord)
+	if err != nil {
+		logger.Error("Error when trying to prepare the reserve idempotency record statement.", err)
+		return false, resterrors.NewInternalServerError("Error when trying to prepare the reserve idempotency record statement.", errors.New("database error"))
+	}
+
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(record.KeyHash, record.Fingerprint, record.DateCreated, record.DateExpires); err != nil {
+		if mysqlutils.IsDuplicateEntry(err) {
+			return false, nil
+		}
+		logger.Error("Error when trying to reserve idempotency record.", err)
+		return false, resterrors.NewInternalServerError("Error when trying to reserve idempotency record.", errors.New("database error"))
+	}
+
+	return true, nil
+}
+
+// get the record by the key hash, or nil when there is none.
+func (r *mysqlRepository) get(keyHash string) (*Record, *resterrors.RestErr) {
+	stmt, err := r.client.Prepare(queryGetRecord)
+	if err != nil {
+		logger.Error("Error when trying to prepare the get idempotency record statement.", err)
+		return nil, resterrors.NewInternalServerError("Error when trying to prepare the get idempotency record statement.", errors.New("database error"))
+	}
+
+	defer stmt.Close()
+
+	record := &Record{KeyHash: keyHash}
+	var headers string
+	if getErr := stmt.QueryRow(keyHash).Scan(&record.Fingerprint, &record.Status, &headers, &record.Body, &record.DateCreated, &record.DateExpires); getErr != nil {
+		if strings.Contains(getErr.Error(), mysqlutils.ErrorNoRows) {
+			return nil, nil
+		}
+		logger.Error("Error when trying to get idempotency record.", getErr)
+		return nil, resterrors.NewInternalServerError("Error when trying to get idempotency record.", errors.New("database error"))
+	}
+
+	if err := json.Unmarshal([]byte(headers), &record.Headers); err != nil {
+		logger.Error("Error when trying to decode the idempotency record headers.", err)
+		return nil, resterrors.NewInternalServerError("Error when trying to decode the idempotency record headers.", errors.New("encoding error"))
+	}
+
+	return record, nil
+}
+
+func (r *mysqlRepository) deleteExpired(keyHash string, now string) *resterrors.RestErr {
+	stmt, err := r.client.Prepare(queryDeleteExpired)
+	if err != nil {
+		logger.Error("Error when trying to prepare the delete expired idempotency record statement.", err)
+		return resterrors.NewInternalServerError("Error when trying to prepare the delete expired idempotency record statement.", errors.New("database error"))
+	}
+
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(keyHash, now); err != nil {
+		logger.Error("Error when trying to delete expired idempotency record.", err)
+		return resterrors.NewInternalServerError("Error when trying to delete expired idempotency record.", errors.New("database error"))
+	}
+
+	return nil
+}
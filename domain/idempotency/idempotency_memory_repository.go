@@ -0,0 +1,71 @@
+package idempotency
+
+import (
+	"sync"
+
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+type memoryRepository struct {
+	mu      sync.Mutex
+	records map[string]Record
+}
+
+// NewMemoryRepository creates a thread-safe Repository keeping the records in memory.
+func NewMemoryRepository() Repository {
+	return &memoryRepository{
+		records: make(map[string]Record),
+	}
+}
+
+// Reserve the record in memory or return the one holding the key.
+func (r *memoryRepository) Reserve(record *Record, now string) (*Record, *resterrors.RestErr) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if current, exists := r.records[record.KeyHash]; exists && current.DateExpires > now {
+		return &current, nil
+	}
+
+	r.records[record.KeyHash] = *record
+
+	return nil, nil
+}
+
+// Complete the record in memory or return the RestErr.
+func (r *memoryRepository) Complete(record *Record) *resterrors.RestErr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.records[record.KeyHash] = *record
+
+	return nil
+}
+
+// Release the record in progress from memory or return the RestErr.
+func (r *memoryRepository) Release(keyHash string) *resterrors.RestErr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if current, exists := r.records[keyHash]; exists && !current.IsCompleted() {
+		delete(r.records, keyHash)
+	}
+
+	return nil
+}
+
+// Purge the records expired before the date from memory or return the RestErr.
+func (r *memoryRepository) Purge(before string) (int64, *resterrors.RestErr) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var purged int64
+	for keyHash, record := range r.records {
+		if record.DateExpires < before {
+			delete(r.records, keyHash)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
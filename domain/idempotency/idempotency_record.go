@@ -0,0 +1,35 @@
+package idempotency
+
+import (
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+// Record is the first response given to a request with an Idempotency-Key, replayed to its
+// retries until DateExpires. A zero Status means the first request is still being handled.
+type Record struct {
+	KeyHash     string
+	Fingerprint string
+	Status      int
+	Headers     map[string]string
+	Body        []byte
+	DateCreated string
+	DateExpires string
+}
+
+// IsCompleted checks if the response of the record is known.
+func (r *Record) IsCompleted() bool {
+	return r.Status != 0
+}
+
+// Repository is the persistence contract of the idempotency records.
+type Repository interface {
+	// Reserve stores the record as in progress. When the key is taken by a record not expired at
+	// the given date, that record is returned instead and nothing is stored.
+	Reserve(*Record, string) (*Record, *resterrors.RestErr)
+	// Complete stores the response of the reserved record.
+	Complete(*Record) *resterrors.RestErr
+	// Release drops the record still in progress, so the key can be retried.
+	Release(string) *resterrors.RestErr
+	// Purge removes the records expired before the date, returning how many.
+	Purge(string) (int64, *resterrors.RestErr)
+}
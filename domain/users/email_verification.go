@@ -0,0 +1,40 @@
+package users
+
+import (
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+// EmailVerificationToken is a single use token proving that the user owns the e-mail address.
+// Only the hash of the token is stored.
+type EmailVerificationToken struct {
+	ID          int64
+	UserID      int64
+	TokenHash   string
+	DateCreated string
+	DateExpires string
+	DateUsed    string
+}
+
+// ResendVerificationRequest is the struct to request a new e-mail verification token.
+type ResendVerificationRequest struct {
+	Email string `json:"email"`
+}
+
+// EmailVerificationRepository is the persistence contract of the e-mail verification tokens.
+type EmailVerificationRepository interface {
+	// Save stores a new token.
+	Save(*EmailVerificationToken) *resterrors.RestErr
+	// Consume marks as used the token with the TokenHash if it is not used nor expired at the
+	// given date, filling the remaining fields or returning a bad request RestErr.
+	Consume(*EmailVerificationToken, string) *resterrors.RestErr
+	// GetLastDateCreated returns the creation date of the newest token of the user, or an
+	// empty string when there is none.
+	GetLastDateCreated(int64) (string, *resterrors.RestErr)
+	// Revoke marks as used at the given date the unused tokens of the user, so the ones sent to
+	// a previous e-mail address can't verify the current one.
+	Revoke(int64, string) *resterrors.RestErr
+}
+
+func newInvalidVerificationTokenError() *resterrors.RestErr {
+	return resterrors.NewBadRequestError("Invalid or expired e-mail verification token.")
+}
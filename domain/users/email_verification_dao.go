@@ -0,0 +1,135 @@
+package users
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/migueloli/bookstore_users-api/logger"
+	"github.com/migueloli/bookstore_users-api/utils/mysqlutils"
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+const (
+	queryInsertEmailVerification      = "INSERT INTO email_verification_tokens(user_id, token_hash, date_created, date_expires) VALUES (?, ?, ?, ?);"
+	queryGetEmailVerification         = "SELECT id, user_id, date_created, date_expires FROM email_verification_tokens WHERE token_hash = ? AND date_used IS NULL AND date_expires > ?;"
+	queryConsumeEmailVerification     = "UPDATE email_verification_tokens SET date_used = ? WHERE id = ? AND date_used IS NULL;"
+	queryGetLastEmailVerificationDate = "SELECT COALESCE(MAX(date_created), '') FROM email_verification_tokens WHERE user_id = ?;"
+	queryRevokeEmailVerifications     = "UPDATE email_verification_tokens SET date_used = ? WHERE user_id = ? AND date_used IS NULL;"
+)
+
+type emailVerificationMySQLRepository struct {
+	client *sql.DB
+}
+
+// NewEmailVerificationMySQLRepository creates the EmailVerificationRepository backed by the given MySQL client.
+func NewEmailVerificationMySQLRepository(client *sql.DB) EmailVerificationRepository {
+	return &emailVerificationMySQLRepository{client: client}
+}
+
+// Save the token in the database or return the RestErr.
+func (r *emailVerificationMySQLRepository) Save(token *EmailVerificationToken) *resterrors.RestErr {
+	stmt, err := r.client.Prepare(queryInsertEmailVerification)
+	if err != nil {
+		logger.Error("Error when trying to prepare the save e-mail verification token statement.", err)
+		return resterrors.NewInternalServerError("Error when trying to prepare the save e-mail verification token statement.", errors.New("database error"))
+	}
+
+	defer stmt.Close()
+
+	insertResult, saveErr := stmt.Exec(token.UserID, token.TokenHash, token.DateCreated, token.DateExpires)
+	if saveErr != nil {
+		logger.Error("Error when trying to save e-mail verification token.", saveErr)
+		return resterrors.NewInternalServerError("Error when trying to save e-mail verification token.", errors.New("database error"))
+	}
+
+	tokenID, err := insertResult.LastInsertId()
+	if err != nil {
+		logger.Error("Error when trying to get the last inserted e-mail verification token ID.", err)
+		return resterrors.NewInternalServerError("Error when trying to get the last inserted e-mail verification token ID.", errors.New("database error"))
+	}
+
+	token.ID = tokenID
+
+	return nil
+}
+
+// Consume the token in the database or return the RestErr. The conditional update guarantees
+// that concurrent requests can't use the same token twice.
+func (r *emailVerificationMySQLRepository) Consume(token *EmailVerificationToken, now string) *resterrors.RestErr {
+	getStmt, err := r.client.Prepare(queryGetEmailVerification)
+	if err != nil {
+		logger.Error("Error when trying to prepare the get e-mail verification token statement.", err)
+		return resterrors.NewInternalServerError("Error when trying to prepare the get e-mail verification token statement.", errors.New("database error"))
+	}
+
+	defer getStmt.Close()
+
+	result := getStmt.QueryRow(token.TokenHash, now)
+	if getErr := result.Scan(&token.ID, &token.UserID, &token.DateCreated, &token.DateExpires); getErr != nil {
+		if strings.Contains(getErr.Error(), mysqlutils.ErrorNoRows) {
+			return newInvalidVerificationTokenError()
+		}
+		logger.Error("Error when trying to get e-mail verification token.", getErr)
+		return resterrors.NewInternalServerError("Error when trying to get e-mail verification token.", errors.New("database error"))
+	}
+
+	consumeStmt, err := r.client.Prepare(queryConsumeEmailVerification)
+	if err != nil {
+		logger.Error("Error when trying to prepare the consume e-mail verification token statement.", err)
+		return resterrors.NewInternalServerError("Error when trying to prepare the consume e-mail verification token statement.", errors.New("database error"))
+	}
+
+	defer consumeStmt.Close()
+
+	updateResult, err := consumeStmt.Exec(now, token.ID)
+	if err != nil {
+		logger.Error("Error when trying to consume e-mail verification token.", err)
+		return resterrors.NewInternalServerError("Error when trying to consume e-mail verification token.", errors.New("database error"))
+	}
+
+	if affected, err := updateResult.RowsAffected(); err != nil || affected == 0 {
+		return newInvalidVerificationTokenError()
+	}
+
+	token.DateUsed = now
+
+	return nil
+}
+
+// GetLastDateCreated of the user tokens in the database or return the RestErr.
+func (r *emailVerificationMySQLRepository) GetLastDateCreated(userID int64) (string, *resterrors.RestErr) {
+	stmt, err := r.client.Prepare(queryGetLastEmailVerificationDate)
+	if err != nil {
+		logger.Error("Error when trying to prepare the get last e-mail verification date statement.", err)
+		return "", resterrors.NewInternalServerError("Error when trying to prepare the get last e-mail verification date statement.", errors.New("database error"))
+	}
+
+	defer stmt.Close()
+
+	var dateCreated string
+	if getErr := stmt.QueryRow(userID).Scan(&dateCreated); getErr != nil {
+		logger.Error("Error when trying to get last e-mail verification date.", getErr)
+		return "", resterrors.NewInternalServerError("Error when trying to get last e-mail verification date.", errors.New("database error"))
+	}
+
+	return dateCreated, nil
+}
+
+// Revoke the user tokens in the database or return the RestErr.
+func (r *emailVerificationMySQLRepository) Revoke(userID int64, now string) *resterrors.RestErr {
+	stmt, err := r.client.Prepare(queryRevokeEmailVerifications)
+	if err != nil {
+		logger.Error("Error when trying to prepare the revoke e-mail verification tokens statement.", err)
+		return resterrors.NewInternalServerError("Error when trying to prepare the revoke e-mail verification tokens statement.", errors.New("database error"))
+	}
+
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(now, userID); err != nil {
+		logger.Error("Error when trying to revoke e-mail verification tokens.", err)
+		return resterrors.NewInternalServerError("Error when trying to revoke e-mail verification tokens.", errors.New("database error"))
+	}
+
+	return nil
+}
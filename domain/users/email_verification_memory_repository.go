@@ -0,0 +1,79 @@
+package users
+
+import (
+	"sync"
+
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+type emailVerificationMemoryRepository struct {
+	mu     sync.Mutex
+	lastID int64
+	tokens map[string]EmailVerificationToken
+}
+
+// NewEmailVerificationMemoryRepository creates a thread-safe EmailVerificationRepository keeping the tokens in memory.
+func NewEmailVerificationMemoryRepository() EmailVerificationRepository {
+	return &emailVerificationMemoryRepository{
+		tokens: make(map[string]EmailVerificationToken),
+	}
+}
+
+// Save the token in memory or return the RestErr.
+func (r *emailVerificationMemoryRepository) Save(token *EmailVerificationToken) *resterrors.RestErr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastID++
+	token.ID = r.lastID
+	r.tokens[token.TokenHash] = *token
+
+	return nil
+}
+
+// Consume the token in memory or return the RestErr.
+func (r *emailVerificationMemoryRepository) Consume(token *EmailVerificationToken, now string) *resterrors.RestErr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, exists := r.tokens[token.TokenHash]
+	if !exists || current.DateUsed != "" || current.DateExpires <= now {
+		return newInvalidVerificationTokenError()
+	}
+
+	current.DateUsed = now
+	r.tokens[token.TokenHash] = current
+	*token = current
+
+	return nil
+}
+
+// GetLastDateCreated of the user tokens in memory or return the RestErr.
+func (r *emailVerificationMemoryRepository) GetLastDateCreated(userID int64) (string, *resterrors.RestErr) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	last := ""
+	for _, token := range r.tokens {
+		if token.UserID == userID && token.DateCreated > last {
+			last = token.DateCreated
+		}
+	}
+
+	return last, nil
+}
+
+// Revoke the user tokens in memory or return the RestErr.
+func (r *emailVerificationMemoryRepository) Revoke(userID int64, now string) *resterrors.RestErr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for hash, token := range r.tokens {
+		if token.UserID == userID && token.DateUsed == "" {
+			token.DateUsed = now
+			r.tokens[hash] = token
+		}
+	}
+
+	return nil
+}
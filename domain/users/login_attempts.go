@@ -0,0 +1,76 @@
+package users
+
+import (
+	"time"
+)
+
+const (
+	// LoginAttemptAccountPrefix is the prefix of the tracker keys identifying an account by e-mail.
+	LoginAttemptAccountPrefix = "email:"
+	// LoginAttemptIPPrefix is the prefix of the tracker keys identifying a client IP.
+	LoginAttemptIPPrefix = "ip:"
+)
+
+var (
+	// DefaultAccountLoginThrottle slows down the guesses against an account and locks it
+	// after 10 consecutive failures.
+	DefaultAccountLoginThrottle = LoginThrottleConfig{
+		FreeAttempts:     3,
+		BaseDelay:        time.Second,
+		MaxDelay:         time.Minute,
+		LockoutThreshold: 10,
+		LockoutDuration:  15 * time.Minute,
+		ResetAfter:       time.Hour,
+	}
+
+	// DefaultIPLoginThrottle slows down a client IP guessing against many accounts, without
+	// locking anything as the IP may be shared.
+	DefaultIPLoginThrottle = LoginThrottleConfig{
+		FreeAttempts: 20,
+		BaseDelay:    time.Second,
+		MaxDelay:     5 * time.Minute,
+		ResetAfter:   time.Hour,
+	}
+)
+
+// LoginThrottleConfig defines how the failed login attempts of a key are punished. After the free
+// attempts each failure doubles the wait for the next attempt, starting at BaseDelay and capped at
+// MaxDelay. A LockoutThreshold greater than zero locks the key for LockoutDuration after that many
+// failures. Failures older than ResetAfter are forgotten.
+type LoginThrottleConfig struct {
+	FreeAttempts     int
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	ResetAfter       time.Duration
+}
+
+// LoginAttemptTracker records the failed login attempts per key. The in-memory implementation only
+// protects a single instance, a shared store must implement it to protect a cluster.
+type LoginAttemptTracker interface {
+	// Check returns for how long the key has to wait before the next attempt and if it is locked out.
+	Check(key string) (time.Duration, bool)
+	// Fail records a failed attempt of the key.
+	Fail(key string)
+	// Succeed forgets the failures of the key after a successful attempt.
+	Succeed(key string)
+	// Unlock forgets the failures and the lockout of the key.
+	Unlock(key string)
+}
+
+// LoginAttempts groups the trackers of the accounts and of the client IPs.
+type LoginAttempts struct {
+	Accounts LoginAttemptTracker
+	IPs      LoginAttemptTracker
+}
+
+// RetryAfter is the RestErr cause telling the client when a refused login can be tried again.
+type RetryAfter struct {
+	RetryAfterSeconds int64 `json:"retry_after_seconds"`
+}
+
+// NewRetryAfter creates the RetryAfter rounding the wait up to the next second.
+func NewRetryAfter(wait time.Duration) RetryAfter {
+	return RetryAfter{RetryAfterSeconds: int64((wait + time.Second - 1) / time.Second)}
+}
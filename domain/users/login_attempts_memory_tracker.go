@@ -0,0 +1,134 @@
+package users
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	memoryTrackerPruneSize = 10000
+)
+
+type loginAttempt struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+type memoryLoginAttemptTracker struct {
+	mu       sync.Mutex
+	config   LoginThrottleConfig
+	attempts map[string]*loginAttempt
+	now      func() time.Time
+}
+
+// NewMemoryLoginAttemptTracker creates a thread-safe LoginAttemptTracker keeping the attempts in memory.
+func NewMemoryLoginAttemptTracker(config LoginThrottleConfig) LoginAttemptTracker {
+	return &memoryLoginAttemptTracker{
+		config:   config,
+		attempts: make(map[string]*loginAttempt),
+		now:      time.Now,
+	}
+}
+
+func (t *memoryLoginAttemptTracker) Check(key string) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	attempt := t.get(key)
+	if attempt == nil {
+		return 0, false
+	}
+
+	now := t.now()
+	if now.Before(attempt.lockedUntil) {
+		return attempt.lockedUntil.Sub(now), true
+	}
+
+	if wait := attempt.lastFailure.Add(t.delay(attempt.failures)).Sub(now); wait > 0 {
+		return wait, false
+	}
+
+	return 0, false
+}
+
+func (t *memoryLoginAttemptTracker) Fail(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.attempts) >= memoryTrackerPruneSize {
+		t.prune()
+	}
+
+	attempt := t.get(key)
+	if attempt == nil {
+		attempt = &loginAttempt{}
+		t.attempts[key] = attempt
+	}
+
+	now := t.now()
+	attempt.failures++
+	attempt.lastFailure = now
+
+	if t.config.LockoutThreshold > 0 && attempt.failures >= t.config.LockoutThreshold {
+		attempt.lockedUntil = now.Add(t.config.LockoutDuration)
+		attempt.failures = 0
+	}
+}
+
+func (t *memoryLoginAttemptTracker) Succeed(key string) {
+	t.Unlock(key)
+}
+
+func (t *memoryLoginAttemptTracker) Unlock(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.attempts, key)
+}
+
+// get returns the attempt of the key, forgetting it when it is stale.
+func (t *memoryLoginAttemptTracker) get(key string) *loginAttempt {
+	attempt, exists := t.attempts[key]
+	if !exists {
+		return nil
+	}
+
+	if t.isStale(attempt) {
+		delete(t.attempts, key)
+		return nil
+	}
+
+	return attempt
+}
+
+func (t *memoryLoginAttemptTracker) isStale(attempt *loginAttempt) bool {
+	now := t.now()
+	return !now.Before(attempt.lockedUntil) && now.Sub(attempt.lastFailure) > t.config.ResetAfter
+}
+
+func (t *memoryLoginAttemptTracker) prune() {
+	for key, attempt := range t.attempts {
+		if t.isStale(attempt) {
+			delete(t.attempts, key)
+		}
+	}
+}
+
+// delay is the wait imposed after the failures, doubling for each one beyond the free attempts.
+func (t *memoryLoginAttemptTracker) delay(failures int) time.Duration {
+	exceeding := failures - t.config.FreeAttempts
+	if exceeding <= 0 {
+		return 0
+	}
+
+	delay := t.config.BaseDelay
+	for i := 1; i < exceeding && delay < t.config.MaxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > t.config.MaxDelay {
+		return t.config.MaxDelay
+	}
+	return delay
+}
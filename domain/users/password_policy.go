@@ -0,0 +1,159 @@
+package users
+
+import (
+	"bufio"
+	// Embedded default denylist of common passwords.
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+const (
+	// DefaultPasswordMinLength is the minimum password length used when none is configured.
+	DefaultPasswordMinLength = 10
+	// DefaultPasswordMaxLength is the maximum password length, bcrypt ignores anything after 72 bytes.
+	DefaultPasswordMaxLength = 72
+	// DefaultPasswordMinClasses is how many of lowercase, uppercase, digits and symbols are required.
+	DefaultPasswordMinClasses = 3
+
+	personalInfoMinLength = 3
+)
+
+var (
+	//go:embed common_passwords.txt
+	defaultDenylist string
+
+	// Policy is the password policy applied to every new password.
+	Policy = NewDefaultPasswordPolicy()
+)
+
+// FieldError describes why the value of a request field was refused.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicy is the set of rules a new password must follow.
+type PasswordPolicy struct {
+	MinLength  int
+	MaxLength  int
+	MinClasses int
+	Denylist   map[string]struct{}
+}
+
+// NewDefaultPasswordPolicy creates the PasswordPolicy with the default rules and the embedded denylist.
+func NewDefaultPasswordPolicy() *PasswordPolicy {
+	denylist, _ := ReadPasswordDenylist(strings.NewReader(defaultDenylist))
+	return &PasswordPolicy{
+		MinLength:  DefaultPasswordMinLength,
+		MaxLength:  DefaultPasswordMaxLength,
+		MinClasses: DefaultPasswordMinClasses,
+		Denylist:   denylist,
+	}
+}
+
+// LoadPasswordDenylist reads the denylist from a file with one password per line.
+func LoadPasswordDenylist(path string) (map[string]struct{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	return ReadPasswordDenylist(file)
+}
+
+// ReadPasswordDenylist reads one password per line, ignoring empty lines and the ones starting with #.
+func ReadPasswordDenylist(reader io.Reader) (map[string]struct{}, error) {
+	denylist := make(map[string]struct{})
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		denylist[strings.ToLower(line)] = struct{}{}
+	}
+
+	return denylist, scanner.Err()
+}
+
+// Validate checks the password of the field against every rule, returning a bad request RestErr
+// with one FieldError cause per broken rule. The user is used to refuse passwords containing
+// personal information and may be nil when it is unknown.
+func (p *PasswordPolicy) Validate(field string, password string, user *User) *resterrors.RestErr {
+	causes := make([]interface{}, 0)
+	addCause := func(code string, message string) {
+		causes = append(causes, FieldError{Field: field, Code: code, Message: message})
+	}
+
+	length := len([]rune(password))
+	if length < p.MinLength {
+		addCause("too_short", fmt.Sprintf("Must have at least %d characters.", p.MinLength))
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		addCause("too_long", fmt.Sprintf("Must have at most %d bytes.", p.MaxLength))
+	}
+
+	if classes := countCharacterClasses(password); classes < p.MinClasses {
+		addCause("too_simple", fmt.Sprintf("Must mix at least %d of lowercase letters, uppercase letters, digits and symbols.", p.MinClasses))
+	}
+
+	lower := strings.ToLower(password)
+	if _, denied := p.Denylist[lower]; denied {
+		addCause("too_common", "Is too common.")
+	}
+
+	if user != nil && containsPersonalInfo(lower, user) {
+		addCause("personal_info", "Must not contain the e-mail or name.")
+	}
+
+	if len(causes) == 0 {
+		return nil
+	}
+
+	restErr := resterrors.NewBadRequestError(fmt.Sprintf("Invalid %s.", strings.ReplaceAll(field, "_", " ")))
+	restErr.Causes = causes
+	return restErr
+}
+
+func countCharacterClasses(password string) int {
+	var lower, upper, digit, symbol int
+	for _, char := range password {
+		switch {
+		case unicode.IsLower(char):
+			lower = 1
+		case unicode.IsUpper(char):
+			upper = 1
+		case unicode.IsDigit(char):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+	return lower + upper + digit + symbol
+}
+
+func containsPersonalInfo(password string, user *User) bool {
+	values := []string{user.FirstName, user.LastName, user.Email}
+	if at := strings.Index(user.Email, "@"); at > 0 {
+		values = append(values, user.Email[:at])
+	}
+
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if len(value) >= personalInfoMinLength && strings.Contains(password, value) {
+			return true
+		}
+	}
+
+	return false
+}
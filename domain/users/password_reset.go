@@ -0,0 +1,82 @@
+package users
+
+import (
+	"strings"
+
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+// PasswordResetToken is a single use token allowing the user to define a new password.
+// Only the hash of the token is stored.
+type PasswordResetToken struct {
+	ID          int64
+	UserID      int64
+	TokenHash   string
+	DateCreated string
+	DateExpires string
+	DateUsed    string
+}
+
+// ForgotPasswordRequest is the struct to request a password reset token.
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// ResetPasswordRequest is the struct to define a new password with a reset token.
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// ChangePasswordRequest is the struct to replace the password knowing the current one.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// PasswordResetRepository is the persistence contract of the password reset tokens.
+type PasswordResetRepository interface {
+	// Save stores a new token.
+	Save(*PasswordResetToken) *resterrors.RestErr
+	// Find fills the token with the TokenHash if it is not used nor expired at the given date
+	// or returns a bad request RestErr, without consuming it.
+	Find(*PasswordResetToken, string) *resterrors.RestErr
+	// Consume marks as used the token with the TokenHash if it is not used nor expired at the
+	// given date, filling the remaining fields or returning a bad request RestErr.
+	Consume(*PasswordResetToken, string) *resterrors.RestErr
+	// InvalidateAll marks as used every outstanding token of the user.
+	InvalidateAll(int64, string) *resterrors.RestErr
+}
+
+// Validate is used to verify if the reset request has the obligated fields. The password is
+// checked apart with the Policy once the user is known.
+func (request *ResetPasswordRequest) Validate() *resterrors.RestErr {
+	request.Token = strings.TrimSpace(request.Token)
+	if request.Token == "" {
+		return newInvalidResetTokenError()
+	}
+
+	return nil
+}
+
+// Validate is used to verify if the change request has the obligated fields. The new password
+// is checked apart with the Policy once the user is known.
+func (request *ChangePasswordRequest) Validate() *resterrors.RestErr {
+	if request.CurrentPassword == "" {
+		return resterrors.NewBadRequestError("Invalid current password.")
+	}
+
+	if request.NewPassword == request.CurrentPassword {
+		restErr := resterrors.NewBadRequestError("Invalid new password.")
+		restErr.Causes = []interface{}{
+			FieldError{Field: "new_password", Code: "unchanged", Message: "Must be different from the current password."},
+		}
+		return restErr
+	}
+
+	return nil
+}
+
+func newInvalidResetTokenError() *resterrors.RestErr {
+	return resterrors.NewBadRequestError("Invalid or expired password reset token.")
+}
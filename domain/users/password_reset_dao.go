@@ -0,0 +1,124 @@
+package users
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/migueloli/bookstore_users-api/logger"
+	"github.com/migueloli/bookstore_users-api/utils/mysqlutils"
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+const (
+	queryInsertPasswordReset         = "INSERT INTO password_reset_tokens(user_id, token_hash, date_created, date_expires) VALUES (?, ?, ?, ?);"
+	queryGetPasswordReset            = "SELECT id, user_id, date_created, date_expires FROM password_reset_tokens WHERE token_hash = ? AND date_used IS NULL AND date_expires > ?;"
+	queryConsumePasswordReset        = "UPDATE password_reset_tokens SET date_used = ? WHERE id = ? AND date_used IS NULL;"
+	queryInvalidatePasswordResetsAll = "UPDATE password_reset_tokens SET date_used = ? WHERE user_id = ? AND date_used IS NULL;"
+)
+
+type passwordResetMySQLRepository struct {
+	client *sql.DB
+}
+
+// NewPasswordResetMySQLRepository creates the PasswordResetRepository backed by the given MySQL client.
+func NewPasswordResetMySQLRepository(client *sql.DB) PasswordResetRepository {
+	return &passwordResetMySQLRepository{client: client}
+}
+
+// Save the token in the database or return the RestErr.
+func (r *passwordResetMySQLRepository) Save(token *PasswordResetToken) *resterrors.RestErr {
+	stmt, err := r.client.Prepare(queryInsertPasswordReset)
+	if err != nil {
+		logger.Error("Error when trying to prepare the save password reset token statement.", err)
+		return resterrors.NewInternalServerError("Error when trying to prepare the save password reset token statement.", errors.New("database error"))
+	}
+
+	defer stmt.Close()
+
+	insertResult, saveErr := stmt.Exec(token.UserID, token.TokenHash, token.DateCreated, token.DateExpires)
+	if saveErr != nil {
+		logger.Error("Error when trying to save password reset token.", saveErr)
+		return resterrors.NewInternalServerError("Error when trying to save password reset token.", errors.New("database error"))
+	}
+
+	tokenID, err := insertResult.LastInsertId()
+	if err != nil {
+		logger.Error("Error when trying to get the last inserted password reset token ID.", err)
+		return resterrors.NewInternalServerError("Error when trying to get the last inserted password reset token ID.", errors.New("database error"))
+	}
+
+	token.ID = tokenID
+
+	return nil
+}
+
+// Find the valid token in the database or return the RestErr.
+func (r *passwordResetMySQLRepository) Find(token *PasswordResetToken, now string) *resterrors.RestErr {
+	stmt, err := r.client.Prepare(queryGetPasswordReset)
+	if err != nil {
+		logger.Error("Error when trying to prepare the get password reset token statement.", err)
+		return resterrors.NewInternalServerError("Error when trying to prepare the get password reset token statement.", errors.New("database error"))
+	}
+
+	defer stmt.Close()
+
+	result := stmt.QueryRow(token.TokenHash, now)
+	if getErr := result.Scan(&token.ID, &token.UserID, &token.DateCreated, &token.DateExpires); getErr != nil {
+		if strings.Contains(getErr.Error(), mysqlutils.ErrorNoRows) {
+			return newInvalidResetTokenError()
+		}
+		logger.Error("Error when trying to get password reset token.", getErr)
+		return resterrors.NewInternalServerError("Error when trying to get password reset token.", errors.New("database error"))
+	}
+
+	return nil
+}
+
+// Consume the token in the database or return the RestErr. The conditional update guarantees
+// that concurrent requests can't use the same token twice.
+func (r *passwordResetMySQLRepository) Consume(token *PasswordResetToken, now string) *resterrors.RestErr {
+	if err := r.Find(token, now); err != nil {
+		return err
+	}
+
+	consumeStmt, err := r.client.Prepare(queryConsumePasswordReset)
+	if err != nil {
+		logger.Error("Error when trying to prepare the consume password reset token statement.", err)
+		return resterrors.NewInternalServerError("Error when trying to prepare the consume password reset token statement.", errors.New("database error"))
+	}
+
+	defer consumeStmt.Close()
+
+	updateResult, err := consumeStmt.Exec(now, token.ID)
+	if err != nil {
+		logger.Error("Error when trying to consume password reset token.", err)
+		return resterrors.NewInternalServerError("Error when trying to consume password reset token.", errors.New("database error"))
+	}
+
+	if affected, err := updateResult.RowsAffected(); err != nil || affected == 0 {
+		return newInvalidResetTokenError()
+	}
+
+	token.DateUsed = now
+
+	return nil
+}
+
+// InvalidateAll the outstanding tokens of the user in the database or return the RestErr.
+func (r *passwordResetMySQLRepository) InvalidateAll(userID int64, now string) *resterrors.RestErr {
+	stmt, err := r.client.Prepare(queryInvalidatePasswordResetsAll)
+	if err != nil {
+		logger.Error("Error when trying to prepare the invalidate password reset tokens statement.", err)
+		return resterrors.NewInternalServerError("Error when trying to prepare the invalidate password reset tokens statement.", errors.New("database error"))
+	}
+
+	defer stmt.Close()
+
+	if _, err = stmt.Exec(now, userID); err != nil {
+		logger.Error("Error when trying to invalidate password reset tokens.", err)
+		return resterrors.NewInternalServerError("Error when trying to invalidate password reset tokens.", errors.New("database error"))
+	}
+
+	return nil
+}
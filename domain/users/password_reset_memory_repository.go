@@ -0,0 +1,79 @@
+package users
+
+import (
+	"sync"
+
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+type passwordResetMemoryRepository struct {
+	mu     sync.Mutex
+	lastID int64
+	tokens map[string]PasswordResetToken
+}
+
+// NewPasswordResetMemoryRepository creates a thread-safe PasswordResetRepository keeping the tokens in memory.
+func NewPasswordResetMemoryRepository() PasswordResetRepository {
+	return &passwordResetMemoryRepository{
+		tokens: make(map[string]PasswordResetToken),
+	}
+}
+
+// Save the token in memory or return the RestErr.
+func (r *passwordResetMemoryRepository) Save(token *PasswordResetToken) *resterrors.RestErr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastID++
+	token.ID = r.lastID
+	r.tokens[token.TokenHash] = *token
+
+	return nil
+}
+
+// Find the valid token in memory or return the RestErr.
+func (r *passwordResetMemoryRepository) Find(token *PasswordResetToken, now string) *resterrors.RestErr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, exists := r.tokens[token.TokenHash]
+	if !exists || current.DateUsed != "" || current.DateExpires <= now {
+		return newInvalidResetTokenError()
+	}
+
+	*token = current
+
+	return nil
+}
+
+// Consume the token in memory or return the RestErr.
+func (r *passwordResetMemoryRepository) Consume(token *PasswordResetToken, now string) *resterrors.RestErr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, exists := r.tokens[token.TokenHash]
+	if !exists || current.DateUsed != "" || current.DateExpires <= now {
+		return newInvalidResetTokenError()
+	}
+
+	current.DateUsed = now
+	r.tokens[token.TokenHash] = current
+	*token = current
+
+	return nil
+}
+
+// InvalidateAll the outstanding tokens of the user in memory or return the RestErr.
+func (r *passwordResetMemoryRepository) InvalidateAll(userID int64, now string) *resterrors.RestErr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for hash, token := range r.tokens {
+		if token.UserID == userID && token.DateUsed == "" {
+			token.DateUsed = now
+			r.tokens[hash] = token
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,147 @@
+package users
+
+import (
+	"fmt"
+
+	"github.com/migueloli/bookstore_users-api/utils/dateutils"
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+// Actions of the audit trail besides the status operations, recorded with their own names.
+const (
+	AuditActionCreate         = "create"
+	AuditActionImport         = "import"
+	AuditActionUpdate         = "update"
+	AuditActionDelete         = "delete"
+	AuditActionRestore        = "restore"
+	AuditActionPurge          = "purge"
+	AuditActionPasswordChange = "password_change"
+	AuditActionPasswordReset  = "password_reset"
+	AuditActionPasswordRehash = "password_rehash"
+	AuditActionRoleGrant      = "role_grant"
+	AuditActionRoleRevoke     = "role_revoke"
+	AuditActionUnlock         = "unlock"
+
+	// AuditRedacted replaces the values of the sensitive fields in the audit trail.
+	AuditRedacted = "[redacted]"
+)
+
+// auditFields are the user fields compared by the audit trail. The sensitive ones only tell that
+// they changed.
+var auditFields = []struct {
+	name      string
+	sensitive bool
+	value     func(*User) string
+}{
+	{name: "first_name", value: func(user *User) string { return user.FirstName }},
+	{name: "last_name", value: func(user *User) string { return user.LastName }},
+	{name: "email", value: func(user *User) string { return user.Email }},
+	{name: "status", value: func(user *User) string { return user.Status }},
+	{name: "status_reason", value: func(user *User) string { return user.StatusReason }},
+	{name: "password", sensitive: true, value: func(user *User) string { return user.Password }},
+	{name: "date_deleted", value: func(user *User) string { return user.DateDeleted }},
+}
+
+// AuditActor is who requested a mutation. UserID is 0 for the anonymous callers and the
+// application itself.
+type AuditActor struct {
+	UserID    int64
+	ClientIP  string
+	RequestID string
+}
+
+// SystemActor is the AuditActor of the mutations done by the application on its own.
+var SystemActor = AuditActor{}
+
+// AuditChange is the value of a field before and after a mutation.
+type AuditChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// AuditEntry is the record of a mutation of the user in the append-only audit trail.
+type AuditEntry struct {
+	ID          int64         `json:"id"`
+	UserID      int64         `json:"user_id"`
+	ActorID     int64         `json:"actor_id"`
+	Action      string        `json:"action"`
+	Changes     []AuditChange `json:"changes"`
+	ClientIP    string        `json:"client_ip"`
+	RequestID   string        `json:"request_id"`
+	DateCreated string        `json:"date_created"`
+}
+
+// NewAuditEntry creates the entry of the action done by the actor on the user, without changes.
+func NewAuditEntry(actor AuditActor, action string, userID int64) *AuditEntry {
+	return &AuditEntry{
+		UserID:      userID,
+		ActorID:     actor.UserID,
+		Action:      action,
+		Changes:     make([]AuditChange, 0),
+		ClientIP:    actor.ClientIP,
+		RequestID:   actor.RequestID,
+		DateCreated: dateutils.GetNowDBString(),
+	}
+}
+
+// Diff sets the changes of the entry to the audited fields differing between the users. A nil
+// before is a creation, every field informed by the after one is a change. The sensitive fields
+// are redacted on both sides, since the before value isn't always loaded.
+func (e *AuditEntry) Diff(before *User, after *User) {
+	created := before == nil
+	if created {
+		before = &User{}
+	}
+
+	e.Changes = make([]AuditChange, 0)
+	for _, field := range auditFields {
+		valueBefore, valueAfter := field.value(before), field.value(after)
+		if valueBefore == valueAfter {
+			continue
+		}
+
+		if field.sensitive {
+			valueBefore, valueAfter = AuditRedacted, AuditRedacted
+			if created {
+				valueBefore = ""
+			}
+		}
+		e.Changes = append(e.Changes, AuditChange{Field: field.name, Before: valueBefore, After: valueAfter})
+	}
+}
+
+// AuditRequest is the struct of the page of the audit trail of an user, newest first.
+type AuditRequest struct {
+	UserID int64
+	Limit  int
+	Offset int
+}
+
+// Validate applies the default page size and checks the page.
+func (r *AuditRequest) Validate() *resterrors.RestErr {
+	if r.UserID <= 0 {
+		return resterrors.NewBadRequestError("User ID has to be greater than 0.")
+	}
+
+	if r.Limit == 0 {
+		r.Limit = DefaultSearchLimit
+	}
+	if r.Limit < 0 || r.Limit > MaxSearchLimit {
+		return resterrors.NewBadRequestError(fmt.Sprintf("Limit should be between 1 and %d.", MaxSearchLimit))
+	}
+
+	if r.Offset < 0 {
+		return resterrors.NewBadRequestError("Offset should not be negative.")
+	}
+
+	return nil
+}
+
+// AuditResult is a page of the audit trail of an user.
+type AuditResult struct {
+	Results []AuditEntry `json:"results"`
+	Total   int64        `json:"total"`
+	Limit   int          `json:"limit"`
+	Offset  int          `json:"offset"`
+}
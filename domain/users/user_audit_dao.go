@@ -0,0 +1,165 @@
+package users
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/migueloli/bookstore_users-api/logger"
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+const (
+	queryInsertAudit      = "INSERT INTO user_audit(user_id, actor_id, action, changes, client_ip, request_id, date_created) VALUES (?, ?, ?, ?, ?, ?, ?);"
+	queryInsertPurgeAudit = "INSERT INTO user_audit(user_id, actor_id, action, changes, client_ip, request_id, date_created) SELECT id, ?, ?, ?, ?, ?, ? FROM users WHERE date_deleted IS NOT NULL AND date_deleted < ?;"
+	queryGetAudit         = "SELECT id, user_id, actor_id, action, changes, client_ip, request_id, date_created FROM user_audit WHERE user_id = ? ORDER BY id DESC LIMIT ? OFFSET ?;"
+	queryCountAudit       = "SELECT COUNT(*) FROM user_audit WHERE user_id = ?;"
+)
+
+// withAudit runs the mutation and appends the entry to the audit trail in a single transaction,
+// so neither is stored without the other.
+func (r *mysqlRepository) withAudit(entry *AuditEntry, mutation func(*sql.Tx) *resterrors.RestErr) *resterrors.RestErr {
+	tx, err := r.client.Begin()
+	if err != nil {
+		logger.Error("Error when trying to begin the user transaction.", err)
+		return resterrors.NewInternalServerError("Error when trying to begin the user transaction.", errors.New("database error"))
+	}
+
+	defer tx.Rollback()
+
+	if restErr := mutation(tx); restErr != nil {
+		return restErr
+	}
+
+	if restErr := insertAudit(tx, entry); restErr != nil {
+		return restErr
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Error when trying to commit the user transaction.", err)
+		return resterrors.NewInternalServerError("Error when trying to commit the user transaction.", errors.New("database error"))
+	}
+
+	return nil
+}
+
+// insertAudit appends the entry to the audit trail within the transaction of its mutation.
+func insertAudit(tx *sql.Tx, entry *AuditEntry) *resterrors.RestErr {
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		logger.Error("Error when trying to encode the audit changes.", err)
+		return resterrors.NewInternalServerError("Error when trying to encode the audit changes.", errors.New("database error"))
+	}
+
+	stmt, err := tx.Prepare(queryInsertAudit)
+	if err != nil {
+		logger.Error("Error when trying to prepare the save audit statement.", err)
+		return resterrors.NewInternalServerError("Error when trying to prepare the save audit statement.", errors.New("database error"))
+	}
+
+	defer stmt.Close()
+
+	insertResult, err := stmt.Exec(entry.UserID, entry.ActorID, entry.Action, changes, entry.ClientIP, entry.RequestID, entry.DateCreated)
+	if err != nil {
+		logger.Error("Error when trying to save audit.", err)
+		return resterrors.NewInternalServerError("Error when trying to save audit.", errors.New("database error"))
+	}
+
+	entryID, err := insertResult.LastInsertId()
+	if err != nil {
+		logger.Error("Error when trying to get the last inserted audit ID.", err)
+		return resterrors.NewInternalServerError("Error when trying to get the last inserted audit ID.", errors.New("database error"))
+	}
+	entry.ID = entryID
+
+	return nil
+}
+
+// insertPurgeAudit appends the entry for every user about to be purged, within the transaction
+// of the purge.
+func insertPurgeAudit(tx *sql.Tx, entry *AuditEntry, before string) *resterrors.RestErr {
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		logger.Error("Error when trying to encode the audit changes.", err)
+		return resterrors.NewInternalServerError("Error when trying to encode the audit changes.", errors.New("database error"))
+	}
+
+	stmt, err := tx.Prepare(queryInsertPurgeAudit)
+	if err != nil {
+		logger.Error("Error when trying to prepare the save purge audit statement.", err)
+		return resterrors.NewInternalServerError("Error when trying to prepare the save purge audit statement.", errors.New("database error"))
+	}
+
+	defer stmt.Close()
+
+	if _, err := stmt.Exec(entry.ActorID, entry.Action, changes, entry.ClientIP, entry.RequestID, entry.DateCreated, before); err != nil {
+		logger.Error("Error when trying to save purge audit.", err)
+		return resterrors.NewInternalServerError("Error when trying to save purge audit.", errors.New("database error"))
+	}
+
+	return nil
+}
+
+// Audit appends the entry to the audit trail in the database or return the RestErr.
+func (r *mysqlRepository) Audit(entry *AuditEntry) *resterrors.RestErr {
+	return r.withAudit(entry, func(*sql.Tx) *resterrors.RestErr { return nil })
+}
+
+// GetAudit the page of the audit trail of the user from the database or return the RestErr.
+func (r *mysqlRepository) GetAudit(request AuditRequest) (*AuditResult, *resterrors.RestErr) {
+	countStmt, err := r.client.Prepare(queryCountAudit)
+	if err != nil {
+		logger.Error("Error when trying to prepare the count audit statement.", err)
+		return nil, resterrors.NewInternalServerError("Error when trying to prepare the count audit statement.", errors.New("database error"))
+	}
+
+	defer countStmt.Close()
+
+	result := &AuditResult{
+		Results: make([]AuditEntry, 0, request.Limit),
+		Limit:   request.Limit,
+		Offset:  request.Offset,
+	}
+	if countErr := countStmt.QueryRow(request.UserID).Scan(&result.Total); countErr != nil {
+		logger.Error("Error when trying to count audit.", countErr)
+		return nil, resterrors.NewInternalServerError("Error when trying to count audit.", errors.New("database error"))
+	}
+
+	stmt, err := r.client.Prepare(queryGetAudit)
+	if err != nil {
+		logger.Error("Error when trying to prepare the get audit statement.", err)
+		return nil, resterrors.NewInternalServerError("Error when trying to prepare the get audit statement.", errors.New("database error"))
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.Query(request.UserID, request.Limit, request.Offset)
+	if err != nil {
+		logger.Error("Error when trying to get audit.", err)
+		return nil, resterrors.NewInternalServerError("Error when trying to get audit.", errors.New("database error"))
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry AuditEntry
+		var changes []byte
+		if getErr := rows.Scan(&entry.ID, &entry.UserID, &entry.ActorID, &entry.Action, &changes, &entry.ClientIP, &entry.RequestID, &entry.DateCreated); getErr != nil {
+			logger.Error("Error when trying to scan the audit row into the audit struct.", getErr)
+			return nil, resterrors.NewInternalServerError("Error when trying to scan the audit row into the audit struct.", errors.New("database error"))
+		}
+
+		if err := json.Unmarshal(changes, &entry.Changes); err != nil {
+			logger.Error("Error when trying to decode the audit changes.", err)
+			return nil, resterrors.NewInternalServerError("Error when trying to decode the audit changes.", errors.New("database error"))
+		}
+		result.Results = append(result.Results, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.Error("Error when trying to get audit.", err)
+		return nil, resterrors.NewInternalServerError("Error when trying to get audit.", errors.New("database error"))
+	}
+
+	return result, nil
+}
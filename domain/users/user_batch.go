@@ -0,0 +1,76 @@
+package users
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+// MaxBatchSize is the biggest number of users created or looked up by a single batch request.
+const MaxBatchSize = 100
+
+// BatchCreateRequest is the struct to request the creation of many users at once.
+type BatchCreateRequest struct {
+	Users []User `json:"users"`
+}
+
+// Validate checks that the batch has between 1 and MaxBatchSize users.
+func (r *BatchCreateRequest) Validate() *resterrors.RestErr {
+	if len(r.Users) == 0 || len(r.Users) > MaxBatchSize {
+		return resterrors.NewBadRequestError(fmt.Sprintf("The batch should have between 1 and %d users.", MaxBatchSize))
+	}
+
+	return nil
+}
+
+// BatchItemResult is the outcome of an user of the batch, by its position in the request. Only
+// one of User and Error is set.
+type BatchItemResult struct {
+	Index int
+	User  *User
+	Error *resterrors.RestErr
+}
+
+// BatchCreateResult is the outcome of every user of the batch creation, in the request order.
+type BatchCreateResult struct {
+	Results []BatchItemResult
+	Created int
+	Failed  int
+}
+
+// BatchLookupResult is the struct of the users found by the batch lookup, sorted by ID, and of
+// the IDs missing or deleted.
+type BatchLookupResult struct {
+	Results Users
+	Missing []int64
+}
+
+// ParseUserIDs parses the comma separated IDs of the batch lookup, keeping the first occurrence of
+// the repeated ones.
+func ParseUserIDs(value string) ([]int64, *resterrors.RestErr) {
+	userIDs := make([]int64, 0)
+	seen := make(map[int64]bool)
+	for _, param := range strings.Split(value, ",") {
+		if param = strings.TrimSpace(param); param == "" {
+			continue
+		}
+
+		userID, err := strconv.ParseInt(param, 10, 64)
+		if err != nil || userID <= 0 {
+			return nil, resterrors.NewBadRequestError(fmt.Sprintf("User ID %s should be a number greater than 0.", param))
+		}
+
+		if !seen[userID] {
+			seen[userID] = true
+			userIDs = append(userIDs, userID)
+		}
+	}
+
+	if len(userIDs) == 0 || len(userIDs) > MaxBatchSize {
+		return nil, resterrors.NewBadRequestError(fmt.Sprintf("The ids param should have between 1 and %d user IDs.", MaxBatchSize))
+	}
+
+	return userIDs, nil
+}
@@ -1,37 +1,83 @@
 package users
 
 import (
+	"database/sql"
 	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 
-	"github.com/migueloli/bookstore_users-api/datasources/mysql/usersdb"
 	"github.com/migueloli/bookstore_users-api/logger"
 	"github.com/migueloli/bookstore_users-api/utils/mysqlutils"
 	"github.com/migueloli/bookstore_utils-go/resterrors"
 )
 
 const (
-	queryInsertUser              = "INSERT INTO users(first_name, last_name, email, date_created, status, password) VALUES (?, ?, ?, ?, ?, ?);"
-	queryGetUser                 = "SELECT id, first_name, last_name, email, date_created, status FROM users WHERE id = ?;"
-	queryUpdateUser              = "UPDATE users SET first_name = ?, last_name = ?, email = ? FROM users WHERE id = ?;"
-	queryDeleteUser              = "DELETE FROM users WHERE id = ?;"
-	queryFindUserByStatus        = "SELECT id, first_name, last_name, email, date_created, status FROM users WHERE status = ?;"
-	queryFindUserByEmailPassword = "SELECT id, first_name, last_name, email, date_created, status FROM users WHERE email = ? AND password = ? AND status = ?;"
+	queryInsertUser      = "INSERT INTO users(first_name, last_name, email, date_created, status, date_status_changed, password, date_password_changed) VALUES (?, ?, ?, ?, ?, ?, ?, ?);"
+	queryGetUsers        = "SELECT id, first_name, last_name, email, date_created, status, status_reason, date_status_changed, date_password_changed, version FROM users WHERE id IN (%s) AND date_deleted IS NULL ORDER BY id;"
+	queryGetUser         = "SELECT id, first_name, last_name, email, date_created, status, status_reason, date_status_changed, date_password_changed, version FROM users WHERE id = ? AND date_deleted IS NULL;"
+	queryUpdateUser      = "UPDATE users SET first_name = ?, last_name = ?, email = ?, status = ?, status_reason = ?, date_status_changed = ?, version = version + 1 WHERE id = ? AND version = ? AND date_deleted IS NULL;"
+	queryDeleteUser      = "UPDATE users SET status_before_delete = status, status = ?, date_deleted = ?, version = version + 1 WHERE id = ? AND version = ? AND date_deleted IS NULL;"
+	queryGetDeletedUser  = "SELECT id, first_name, last_name, email, status, status_reason, date_deleted FROM users WHERE id = ? AND date_deleted IS NOT NULL FOR UPDATE;"
+	queryRestoreUser     = "UPDATE users SET status = COALESCE(status_before_delete, ?), status_before_delete = NULL, date_deleted = NULL, version = version + 1 WHERE id = ? AND date_deleted IS NOT NULL;"
+	queryPurgeUsers      = "DELETE FROM users WHERE date_deleted IS NOT NULL AND date_deleted < ?;"
+	querySearchUsers     = "SELECT id, first_name, last_name, email, date_created, status, status_reason, date_status_changed, date_password_changed, version, %s AS score FROM users"
+	queryStreamUsers     = "SELECT id, first_name, last_name, email, date_created, status, status_reason, date_status_changed, date_password_changed, version FROM users"
+	queryCountUsers      = "SELECT COUNT(*) FROM users"
+	queryFindUserByEmail = "SELECT id, first_name, last_name, email, date_created, status, status_reason, date_status_changed, date_password_changed, version, password FROM users WHERE email = ? AND date_deleted IS NULL;"
+	queryUpdatePassword  = "UPDATE users SET password = ?, date_password_changed = ?, version = version + 1 WHERE id = ? AND version = ? AND date_deleted IS NULL;"
+	queryUpdateStatus    = "UPDATE users SET status = ?, status_reason = ?, date_status_changed = ?, version = version + 1 WHERE id = ? AND version = ? AND date_deleted IS NULL;"
+	queryCountUser       = "SELECT COUNT(*) FROM users WHERE id = ? AND date_deleted IS NULL;"
+
+	// textSearchMatch uses the users_text_search FULLTEXT index. InnoDB skips the terms shorter
+	// than innodb_ft_min_token_size, so those are matched with LIKE on textSearchWords instead.
+	textSearchMatch    = "MATCH(first_name, last_name, email) AGAINST (? IN BOOLEAN MODE)"
+	textSearchWords    = "CONCAT(' ', first_name, ' ', last_name, ' ', REPLACE(REPLACE(email, '@', ' '), '.', ' '))"
+	textSearchMinToken = 3
 )
 
+var searchColumns = map[string]string{
+	SortID:          "id",
+	SortDateCreated: "date_created",
+	SortLastName:    "last_name",
+}
+
+type mysqlRepository struct {
+	client *sql.DB
+}
+
+// NewMySQLRepository creates the UserRepository backed by the given MySQL client.
+func NewMySQLRepository(client *sql.DB) UserRepository {
+	return &mysqlRepository{client: client}
+}
+
 // Save the user in the database or return the RestErr.
-func (user *User) Save() *resterrors.RestErr {
-	stmt, err := usersdb.Client.Prepare(queryInsertUser)
-	if err != nil {
-		logger.Error("Error when trying to prepare the save user statement.", err)
-		return resterrors.NewInternalServerError("Error when trying to prepare the save user statement.", errors.New("database error"))
-	}
+func (r *mysqlRepository) Save(user *User, entry *AuditEntry) *resterrors.RestErr {
+	return r.withAudit(entry, func(tx *sql.Tx) *resterrors.RestErr {
+		stmt, err := tx.Prepare(queryInsertUser)
+		if err != nil {
+			logger.Error("Error when trying to prepare the save user statement.", err)
+			return resterrors.NewInternalServerError("Error when trying to prepare the save user statement.", errors.New("database error"))
+		}
 
-	defer stmt.Close()
+		defer stmt.Close()
 
-	insertResult, saveErr := stmt.Exec(user.FirstName, user.LastName, user.Email, user.DateCreated, user.Status, user.Password)
+		if saveErr := insertUser(stmt, user); saveErr != nil {
+			return saveErr
+		}
+		entry.UserID = user.ID
+
+		return nil
+	})
+}
+
+// insertUser runs the prepared insert for the user, setting its ID and first Version.
+func insertUser(stmt *sql.Stmt, user *User) *resterrors.RestErr {
+	insertResult, saveErr := stmt.Exec(user.FirstName, user.LastName, user.Email, user.DateCreated, user.Status, user.DateStatusChanged, user.Password, user.DatePasswordChanged)
 	if saveErr != nil {
+		if mysqlutils.IsDuplicateEntry(saveErr) {
+			return newEmailAlreadyExistsError(user.Email)
+		}
 		logger.Error("Error when trying to save user.", saveErr)
 		return resterrors.NewInternalServerError("Error when trying to save user.", errors.New("database error"))
 	}
@@ -43,13 +89,57 @@ func (user *User) Save() *resterrors.RestErr {
 	}
 
 	user.ID = userID
+	user.Version = 1
 
 	return nil
 }
 
+// SaveAll saves the users in the database in a single transaction or return the RestErr.
+func (r *mysqlRepository) SaveAll(users Users, entries []*AuditEntry) ([]*resterrors.RestErr, *resterrors.RestErr) {
+	tx, err := r.client.Begin()
+	if err != nil {
+		logger.Error("Error when trying to begin the save users transaction.", err)
+		return nil, resterrors.NewInternalServerError("Error when trying to begin the save users transaction.", errors.New("database error"))
+	}
+
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(queryInsertUser)
+	if err != nil {
+		logger.Error("Error when trying to prepare the save user statement.", err)
+		return nil, resterrors.NewInternalServerError("Error when trying to prepare the save user statement.", errors.New("database error"))
+	}
+
+	defer stmt.Close()
+
+	// InnoDB only rolls back the failed statement on a duplicate entry, so the transaction goes on.
+	errs := make([]*resterrors.RestErr, len(users))
+	for index := range users {
+		if saveErr := insertUser(stmt, &users[index]); saveErr != nil {
+			if saveErr.Status == http.StatusInternalServerError {
+				return nil, saveErr
+			}
+			errs[index] = saveErr
+			continue
+		}
+
+		entries[index].UserID = users[index].ID
+		if auditErr := insertAudit(tx, entries[index]); auditErr != nil {
+			return nil, auditErr
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Error when trying to commit the save users transaction.", err)
+		return nil, resterrors.NewInternalServerError("Error when trying to commit the save users transaction.", errors.New("database error"))
+	}
+
+	return errs, nil
+}
+
 // Get the user from the database or return a RestErr.
-func (user *User) Get() *resterrors.RestErr {
-	stmt, err := usersdb.Client.Prepare(queryGetUser)
+func (r *mysqlRepository) Get(user *User) *resterrors.RestErr {
+	stmt, err := r.client.Prepare(queryGetUser)
 	if err != nil {
 		logger.Error("Error when trying to prepare the get user statement.", err)
 		return resterrors.NewInternalServerError("Error when trying to prepare the get user statement.", errors.New("database error"))
@@ -58,7 +148,10 @@ func (user *User) Get() *resterrors.RestErr {
 	defer stmt.Close()
 
 	result := stmt.QueryRow(user.ID)
-	if getErr := result.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.DateCreated, &user.Status); getErr != nil {
+	if getErr := result.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.DateCreated, &user.Status, &user.StatusReason, &user.DateStatusChanged, &user.DatePasswordChanged, &user.Version); getErr != nil {
+		if strings.Contains(getErr.Error(), mysqlutils.ErrorNoRows) {
+			return newUserNotFoundError(user.ID)
+		}
 		logger.Error("Error when trying to get user.", getErr)
 		return resterrors.NewInternalServerError("Error when trying to get user.", errors.New("database error"))
 	}
@@ -66,95 +159,510 @@ func (user *User) Get() *resterrors.RestErr {
 	return nil
 }
 
-// Update the user in the database or return the RestErr.
-func (user *User) Update() *resterrors.RestErr {
-	stmt, err := usersdb.Client.Prepare(queryUpdateUser)
+// GetAll the users with the IDs from the database in a single query or return the RestErr.
+func (r *mysqlRepository) GetAll(userIDs []int64) (Users, *resterrors.RestErr) {
+	result := make(Users, 0, len(userIDs))
+	if len(userIDs) == 0 {
+		return result, nil
+	}
+
+	args := make([]interface{}, len(userIDs))
+	for index, userID := range userIDs {
+		args[index] = userID
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(userIDs)), ", ")
+
+	stmt, err := r.client.Prepare(fmt.Sprintf(queryGetUsers, placeholders))
 	if err != nil {
-		logger.Error("Error when trying to prepare the update user statement.", err)
-		return resterrors.NewInternalServerError("Error when trying to prepare the update user statement.", errors.New("database error"))
+		logger.Error("Error when trying to prepare the get users statement.", err)
+		return nil, resterrors.NewInternalServerError("Error when trying to prepare the get users statement.", errors.New("database error"))
 	}
 
 	defer stmt.Close()
 
-	if _, err = stmt.Exec(user.FirstName, user.LastName, user.Email, user.ID); err != nil {
-		logger.Error("Error when trying to update user.", err)
-		return resterrors.NewInternalServerError("Error when trying to update user.", errors.New("database error"))
+	rows, err := stmt.Query(args...)
+	if err != nil {
+		logger.Error("Error when trying to get users.", err)
+		return nil, resterrors.NewInternalServerError("Error when trying to get users.", errors.New("database error"))
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var user User
+		if getErr := rows.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.DateCreated, &user.Status, &user.StatusReason, &user.DateStatusChanged, &user.DatePasswordChanged, &user.Version); getErr != nil {
+			logger.Error("Error when trying to scan the user row into the user struct.", getErr)
+			return nil, resterrors.NewInternalServerError("Error when trying to scan the user row into the user struct.", errors.New("database error"))
+		}
+		result = append(result, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.Error("Error when trying to get users.", err)
+		return nil, resterrors.NewInternalServerError("Error when trying to get users.", errors.New("database error"))
+	}
+
+	return result, nil
+}
+
+// Update the user in the database or return the RestErr.
+func (r *mysqlRepository) Update(user *User, entry *AuditEntry) *resterrors.RestErr {
+	err := r.withAudit(entry, func(tx *sql.Tx) *resterrors.RestErr {
+		stmt, err := tx.Prepare(queryUpdateUser)
+		if err != nil {
+			logger.Error("Error when trying to prepare the update user statement.", err)
+			return resterrors.NewInternalServerError("Error when trying to prepare the update user statement.", errors.New("database error"))
+		}
+
+		defer stmt.Close()
+
+		updateResult, err := stmt.Exec(user.FirstName, user.LastName, user.Email, user.Status, user.StatusReason, user.DateStatusChanged, user.ID, user.Version)
+		if err != nil {
+			if mysqlutils.IsDuplicateEntry(err) {
+				return newEmailAlreadyExistsError(user.Email)
+			}
+			logger.Error("Error when trying to update user.", err)
+			return resterrors.NewInternalServerError("Error when trying to update user.", errors.New("database error"))
+		}
+
+		if affected, err := updateResult.RowsAffected(); err == nil && affected == 0 {
+			return newVersionMismatchError(user.ID)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
+	user.Version++
+
 	return nil
 }
 
-// Delete the user in the database or return the RestErr.
-func (user *User) Delete() *resterrors.RestErr {
-	stmt, err := usersdb.Client.Prepare(queryDeleteUser)
+// UpdatePassword replaces the password hash and its change date of the user in the database or return the RestErr.
+func (r *mysqlRepository) UpdatePassword(user *User, entry *AuditEntry) *resterrors.RestErr {
+	err := r.withAudit(entry, func(tx *sql.Tx) *resterrors.RestErr {
+		stmt, err := tx.Prepare(queryUpdatePassword)
+		if err != nil {
+			logger.Error("Error when trying to prepare the update password statement.", err)
+			return resterrors.NewInternalServerError("Error when trying to prepare the update password statement.", errors.New("database error"))
+		}
+
+		defer stmt.Close()
+
+		updateResult, err := stmt.Exec(user.Password, user.DatePasswordChanged, user.ID, user.Version)
+		if err != nil {
+			logger.Error("Error when trying to update password.", err)
+			return resterrors.NewInternalServerError("Error when trying to update password.", errors.New("database error"))
+		}
+
+		if affected, err := updateResult.RowsAffected(); err == nil && affected == 0 {
+			return getUnchangedError(tx, user.ID)
+		}
+
+		return nil
+	})
 	if err != nil {
-		logger.Error("Error when trying to prepare the delete user statement.", err)
-		return resterrors.NewInternalServerError("Error when trying to prepare the delete user statement.", errors.New("database error"))
+		return err
 	}
 
-	defer stmt.Close()
+	user.Version++
+
+	return nil
+}
+
+// UpdateStatus of the user in the database or return the RestErr.
+func (r *mysqlRepository) UpdateStatus(user *User, entry *AuditEntry) *resterrors.RestErr {
+	err := r.withAudit(entry, func(tx *sql.Tx) *resterrors.RestErr {
+		stmt, err := tx.Prepare(queryUpdateStatus)
+		if err != nil {
+			logger.Error("Error when trying to prepare the update status statement.", err)
+			return resterrors.NewInternalServerError("Error when trying to prepare the update status statement.", errors.New("database error"))
+		}
+
+		defer stmt.Close()
+
+		updateResult, err := stmt.Exec(user.Status, user.StatusReason, user.DateStatusChanged, user.ID, user.Version)
+		if err != nil {
+			logger.Error("Error when trying to update status.", err)
+			return resterrors.NewInternalServerError("Error when trying to update status.", errors.New("database error"))
+		}
+
+		if affected, err := updateResult.RowsAffected(); err == nil && affected == 0 {
+			return getUnchangedError(tx, user.ID)
+		}
 
-	if _, err = stmt.Exec(user.ID); err != nil {
-		logger.Error("Error when trying to delete user.", err)
-		return resterrors.NewInternalServerError("Error when trying to delete user.", errors.New("database error"))
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
+	user.Version++
+
 	return nil
 }
 
-// FindByStatus is a function to find the user using the status from the database or returning a RestErr
-func (user *User) FindByStatus(status string) ([]User, *resterrors.RestErr) {
-	stmt, err := usersdb.Client.Prepare(queryFindUserByStatus)
+// getUnchangedError tells why a write conditional on the Version changed no row: the user is
+// missing or deleted, or another request changed it first.
+func getUnchangedError(tx *sql.Tx, userID int64) *resterrors.RestErr {
+	var count int64
+	if err := tx.QueryRow(queryCountUser, userID).Scan(&count); err != nil {
+		logger.Error("Error when trying to count user.", err)
+		return resterrors.NewInternalServerError("Error when trying to count user.", errors.New("database error"))
+	}
+
+	if count == 0 {
+		return newUserNotFoundError(userID)
+	}
+
+	return newConcurrentChangeError(userID)
+}
+
+// Delete marks the user as deleted in the database or return the RestErr.
+func (r *mysqlRepository) Delete(user *User, entry *AuditEntry) *resterrors.RestErr {
+	err := r.withAudit(entry, func(tx *sql.Tx) *resterrors.RestErr {
+		stmt, err := tx.Prepare(queryDeleteUser)
+		if err != nil {
+			logger.Error("Error when trying to prepare the delete user statement.", err)
+			return resterrors.NewInternalServerError("Error when trying to prepare the delete user statement.", errors.New("database error"))
+		}
+
+		defer stmt.Close()
+
+		deleteResult, err := stmt.Exec(StatusDeleted, user.DateDeleted, user.ID, user.Version)
+		if err != nil {
+			logger.Error("Error when trying to delete user.", err)
+			return resterrors.NewInternalServerError("Error when trying to delete user.", errors.New("database error"))
+		}
+
+		if affected, err := deleteResult.RowsAffected(); err == nil && affected == 0 {
+			return newVersionMismatchError(user.ID)
+		}
+
+		return nil
+	})
 	if err != nil {
-		logger.Error("Error when trying to prepare the find users by status statement.", err)
-		return nil, resterrors.NewInternalServerError("Error when trying to prepare the find users by status statement.", errors.New("database error"))
+		return err
+	}
+
+	user.Status = StatusDeleted
+	user.Version++
+
+	return nil
+}
+
+// Restore the deleted user in the database or return the RestErr.
+func (r *mysqlRepository) Restore(user *User, entry *AuditEntry) *resterrors.RestErr {
+	return r.withAudit(entry, func(tx *sql.Tx) *resterrors.RestErr {
+		var before User
+		if getErr := tx.QueryRow(queryGetDeletedUser, user.ID).Scan(&before.ID, &before.FirstName, &before.LastName, &before.Email, &before.Status, &before.StatusReason, &before.DateDeleted); getErr != nil {
+			if strings.Contains(getErr.Error(), mysqlutils.ErrorNoRows) {
+				return newDeletedUserNotFoundError(user.ID)
+			}
+			logger.Error("Error when trying to get deleted user.", getErr)
+			return resterrors.NewInternalServerError("Error when trying to get deleted user.", errors.New("database error"))
+		}
+
+		stmt, err := tx.Prepare(queryRestoreUser)
+		if err != nil {
+			logger.Error("Error when trying to prepare the restore user statement.", err)
+			return resterrors.NewInternalServerError("Error when trying to prepare the restore user statement.", errors.New("database error"))
+		}
+
+		defer stmt.Close()
+
+		if _, err := stmt.Exec(StatusPending, user.ID); err != nil {
+			logger.Error("Error when trying to restore user.", err)
+			return resterrors.NewInternalServerError("Error when trying to restore user.", errors.New("database error"))
+		}
+
+		if getErr := tx.QueryRow(queryGetUser, user.ID).Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.DateCreated, &user.Status, &user.StatusReason, &user.DateStatusChanged, &user.DatePasswordChanged, &user.Version); getErr != nil {
+			logger.Error("Error when trying to get restored user.", getErr)
+			return resterrors.NewInternalServerError("Error when trying to get restored user.", errors.New("database error"))
+		}
+
+		entry.Diff(&before, user)
+
+		return nil
+	})
+}
+
+// Purge the users deleted before the date from the database or return the RestErr.
+func (r *mysqlRepository) Purge(before string, entry *AuditEntry) (int64, *resterrors.RestErr) {
+	tx, err := r.client.Begin()
+	if err != nil {
+		logger.Error("Error when trying to begin the purge users transaction.", err)
+		return 0, resterrors.NewInternalServerError("Error when trying to begin the purge users transaction.", errors.New("database error"))
+	}
+
+	defer tx.Rollback()
+
+	if auditErr := insertPurgeAudit(tx, entry, before); auditErr != nil {
+		return 0, auditErr
+	}
+
+	stmt, err := tx.Prepare(queryPurgeUsers)
+	if err != nil {
+		logger.Error("Error when trying to prepare the purge users statement.", err)
+		return 0, resterrors.NewInternalServerError("Error when trying to prepare the purge users statement.", errors.New("database error"))
 	}
 
 	defer stmt.Close()
 
-	rows, err := stmt.Query(status)
+	purgeResult, err := stmt.Exec(before)
 	if err != nil {
-		logger.Error("Error when trying to find users by status statement.", err)
-		return nil, resterrors.NewInternalServerError("Error when trying to find users by status statement.", errors.New("database error"))
+		logger.Error("Error when trying to purge users.", err)
+		return 0, resterrors.NewInternalServerError("Error when trying to purge users.", errors.New("database error"))
 	}
 
-	defer rows.Close()
+	purged, err := purgeResult.RowsAffected()
+	if err != nil {
+		logger.Error("Error when trying to get the purged users count.", err)
+		return 0, resterrors.NewInternalServerError("Error when trying to get the purged users count.", errors.New("database error"))
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Error when trying to commit the purge users transaction.", err)
+		return 0, resterrors.NewInternalServerError("Error when trying to commit the purge users transaction.", errors.New("database error"))
+	}
+
+	return purged, nil
+}
+
+// Search the page of the users matching the validated request in the database or return the RestErr.
+func (r *mysqlRepository) Search(request SearchRequest) (*SearchResult, *resterrors.RestErr) {
+	where, args := buildSearchFilters(&request)
+
+	countStmt, err := r.client.Prepare(queryCountUsers + where + ";")
+	if err != nil {
+		logger.Error("Error when trying to prepare the count users statement.", err)
+		return nil, resterrors.NewInternalServerError("Error when trying to prepare the count users statement.", errors.New("database error"))
+	}
 
-	results := make([]User, 0)
+	defer countStmt.Close()
 
+	result := &SearchResult{
+		Results: make(Users, 0, request.Limit),
+		Limit:   request.Limit,
+		Offset:  request.Offset,
+	}
+	if countErr := countStmt.QueryRow(args...).Scan(&result.Total); countErr != nil {
+		logger.Error("Error when trying to count users.", countErr)
+		return nil, resterrors.NewInternalServerError("Error when trying to count users.", errors.New("database error"))
+	}
+
+	score, scoreArgs := buildSearchScore(&request)
+
+	column, direction, comparison := searchColumns[request.Sort], "ASC", ">"
+	if request.Order == OrderDesc {
+		direction, comparison = "DESC", "<"
+	}
+
+	if value, id, ok := request.CursorValue(); ok {
+		if request.Sort == SortID {
+			where = appendSearchFilter(where, "id "+comparison+" ?")
+			args = append(args, id)
+		} else {
+			where = appendSearchFilter(where, fmt.Sprintf("(%s %s ? OR (%s = ? AND id %s ?))", column, comparison, column, comparison))
+			args = append(args, value, value, id)
+		}
+	}
+
+	order := fmt.Sprintf("%s %s, id %s", column, direction, direction)
+	if request.Sort == SortRelevance {
+		order = fmt.Sprintf("score %s, id ASC", direction)
+	}
+
+	query := fmt.Sprintf(querySearchUsers, score) + where + " ORDER BY " + order + " LIMIT ? OFFSET ?;"
+	args = append(append(scoreArgs, args...), request.Limit+1, request.Offset)
+
+	stmt, err := r.client.Prepare(query)
+	if err != nil {
+		logger.Error("Error when trying to prepare the search users statement.", err)
+		return nil, resterrors.NewInternalServerError("Error when trying to prepare the search users statement.", errors.New("database error"))
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.Query(args...)
+	if err != nil {
+		logger.Error("Error when trying to search users.", err)
+		return nil, resterrors.NewInternalServerError("Error when trying to search users.", errors.New("database error"))
+	}
+
+	defer rows.Close()
+
+	scores := make(map[int64]float64)
 	for rows.Next() {
-		var result User
-		if getErr := rows.Scan(&result.ID, &result.FirstName, &result.LastName, &result.Email, &result.DateCreated, &result.Status); getErr != nil {
-			logger.Error("Error when trying to scan the user row into the user struct.", err)
+		var user User
+		var userScore float64
+		if getErr := rows.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.DateCreated, &user.Status, &user.StatusReason, &user.DateStatusChanged, &user.DatePasswordChanged, &user.Version, &userScore); getErr != nil {
+			logger.Error("Error when trying to scan the user row into the user struct.", getErr)
 			return nil, resterrors.NewInternalServerError("Error when trying to scan the user row into the user struct.", errors.New("database error"))
 		}
-		results = append(results, result)
+
+		if len(result.Results) == request.Limit {
+			last := result.Results[len(result.Results)-1]
+			result.NextCursor = request.NextCursor(&last)
+			break
+		}
+		result.Results = append(result.Results, user)
+		scores[user.ID] = userScore
 	}
 
-	if len(results) == 0 {
-		return nil, resterrors.NewNotFoundError(fmt.Sprintf("No users matching status %s.", status))
+	if err := rows.Err(); err != nil {
+		logger.Error("Error when trying to search users.", err)
+		return nil, resterrors.NewInternalServerError("Error when trying to search users.", errors.New("database error"))
+	}
+
+	if request.IsTextSearch() {
+		result.Hits = make(map[int64]SearchHit, len(result.Results))
+		for index := range result.Results {
+			user := &result.Results[index]
+			result.Hits[user.ID] = NewSearchHit(user, request.Terms(), scores[user.ID])
+		}
+	}
+
+	return result, nil
+}
+
+// Stream the users matching the validated request from the database, reading the rows as the
+// function consumes them.
+func (r *mysqlRepository) Stream(request SearchRequest, fn func(*User) error) *resterrors.RestErr {
+	where, args := buildSearchFilters(&request)
+
+	stmt, err := r.client.Prepare(queryStreamUsers + where + " ORDER BY id;")
+	if err != nil {
+		logger.Error("Error when trying to prepare the stream users statement.", err)
+		return resterrors.NewInternalServerError("Error when trying to prepare the stream users statement.", errors.New("database error"))
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.Query(args...)
+	if err != nil {
+		logger.Error("Error when trying to stream users.", err)
+		return resterrors.NewInternalServerError("Error when trying to stream users.", errors.New("database error"))
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var user User
+		if getErr := rows.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.DateCreated, &user.Status, &user.StatusReason, &user.DateStatusChanged, &user.DatePasswordChanged, &user.Version); getErr != nil {
+			logger.Error("Error when trying to scan the user row into the user struct.", getErr)
+			return resterrors.NewInternalServerError("Error when trying to scan the user row into the user struct.", errors.New("database error"))
+		}
+
+		if err := fn(&user); err != nil {
+			return newStreamError(err)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.Error("Error when trying to stream users.", err)
+		return resterrors.NewInternalServerError("Error when trying to stream users.", errors.New("database error"))
+	}
+
+	return nil
+}
+
+// buildSearchFilters returns the WHERE clause and its arguments for the filters of the request,
+// always hiding the deleted users.
+func buildSearchFilters(request *SearchRequest) (string, []interface{}) {
+	where, args := " WHERE date_deleted IS NULL", make([]interface{}, 0)
+
+	if match := textSearchBooleanQuery(request); match != "" {
+		where = appendSearchFilter(where, textSearchMatch)
+		args = append(args, match)
+	}
+
+	for _, term := range request.Terms() {
+		if len(term) < textSearchMinToken {
+			where = appendSearchFilter(where, textSearchWords+" LIKE ?")
+			args = append(args, "% "+mysqlutils.EscapeLike(term)+"%")
+		}
+	}
+
+	if request.Status != "" {
+		where = appendSearchFilter(where, "status = ?")
+		args = append(args, request.Status)
+	}
+
+	if request.EmailDomain != "" {
+		where = appendSearchFilter(where, "email LIKE ?")
+		args = append(args, "%@"+mysqlutils.EscapeLike(request.EmailDomain))
+	}
+
+	if request.NamePrefix != "" {
+		prefix := mysqlutils.EscapeLike(request.NamePrefix) + "%"
+		where = appendSearchFilter(where, "(first_name LIKE ? OR last_name LIKE ?)")
+		args = append(args, prefix, prefix)
+	}
+
+	if request.CreatedFrom != "" {
+		where = appendSearchFilter(where, "date_created >= ?")
+		args = append(args, request.CreatedFrom)
+	}
+
+	if request.CreatedTo != "" {
+		where = appendSearchFilter(where, "date_created <= ?")
+		args = append(args, request.CreatedTo)
+	}
+
+	return where, args
+}
+
+// buildSearchScore returns the relevance of the rows, the FULLTEXT score when any term is long
+// enough to be indexed.
+func buildSearchScore(request *SearchRequest) (string, []interface{}) {
+	if match := textSearchBooleanQuery(request); match != "" {
+		return textSearchMatch, []interface{}{match}
+	}
+
+	return "0", make([]interface{}, 0)
+}
+
+// textSearchBooleanQuery requires every indexed term as a word prefix. The terms only hold letters
+// and digits, so they can't carry boolean operators.
+func textSearchBooleanQuery(request *SearchRequest) string {
+	required := make([]string, 0, len(request.Terms()))
+	for _, term := range request.Terms() {
+		if len(term) >= textSearchMinToken {
+			required = append(required, "+"+term+"*")
+		}
+	}
+
+	return strings.Join(required, " ")
+}
+
+func appendSearchFilter(where string, filter string) string {
+	if where == "" {
+		return " WHERE " + filter
 	}
 
-	return results, nil
+	return where + " AND " + filter
 }
 
-// FindByEmailPassword the user from the database with a e-mail and password.
-func (user *User) FindByEmailPassword() *resterrors.RestErr {
-	stmt, err := usersdb.Client.Prepare(queryFindUserByEmailPassword)
+// FindByEmail the user from the database with a e-mail, including the password hash.
+func (r *mysqlRepository) FindByEmail(user *User) *resterrors.RestErr {
+	stmt, err := r.client.Prepare(queryFindUserByEmail)
 	if err != nil {
-		logger.Error("Error when trying to prepare the get user by e-mail and password statement", err)
-		return resterrors.NewInternalServerError("Error when trying to prepare the get user by e-mail and password statement", errors.New("database error"))
+		logger.Error("Error when trying to prepare the get user by e-mail statement", err)
+		return resterrors.NewInternalServerError("Error when trying to prepare the get user by e-mail statement", errors.New("database error"))
 	}
 
 	defer stmt.Close()
 
-	result := stmt.QueryRow(user.Email, user.Password, StatusActive)
-	if getErr := result.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.DateCreated, &user.Status); getErr != nil {
+	result := stmt.QueryRow(user.Email)
+	if getErr := result.Scan(&user.ID, &user.FirstName, &user.LastName, &user.Email, &user.DateCreated, &user.Status, &user.StatusReason, &user.DateStatusChanged, &user.DatePasswordChanged, &user.Version, &user.Password); getErr != nil {
 		if strings.Contains(getErr.Error(), mysqlutils.ErrorNoRows) {
-			return resterrors.NewNotFoundError("Invalid user credentials.")
+			return newInvalidCredentialsError()
 		}
-		logger.Error("Error when trying to get user by e-mail and password.", getErr)
-		return resterrors.NewInternalServerError("Error when trying to get user by e-mail and password.", errors.New("database error"))
+		logger.Error("Error when trying to get user by e-mail.", getErr)
+		return resterrors.NewInternalServerError("Error when trying to get user by e-mail.", errors.New("database error"))
 	}
 
 	return nil
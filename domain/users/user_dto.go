@@ -7,8 +7,16 @@ import (
 )
 
 const (
+	// StatusPending is the constant to inform the user status as waiting the e-mail verification
+	StatusPending = "pending"
 	// StatusActive is the constant to inform the user status as active
 	StatusActive = "active"
+	// StatusSuspended is the constant to inform the user status as temporarily barred by an admin
+	StatusSuspended = "suspended"
+	// StatusBanned is the constant to inform the user status as permanently barred by an admin
+	StatusBanned = "banned"
+	// StatusDeleted is the constant to inform the user status as deleted, hidden until restored or purged
+	StatusDeleted = "deleted"
 )
 
 // User is the base of this domain
@@ -20,13 +28,19 @@ type User struct {
 	DateCreated string `json:"date_created"`
 	Status      string `json:"status"`
 	Password    string `json:"password"`
+
+	StatusReason        string `json:"status_reason"`
+	DateStatusChanged   string `json:"date_status_changed"`
+	DatePasswordChanged string `json:"date_password_changed"`
+	DateDeleted         string `json:"-"`
+	Version             int64  `json:"-"`
 }
 
 // Users is a slice of user.
 type Users []User
 
 // Validate is used to verify if the user struct has the obligated fields
-// are correctly fulfilled
+// are correctly fulfilled. The password is checked apart with the Policy.
 func (user *User) Validate() *resterrors.RestErr {
 	user.FirstName = strings.TrimSpace(strings.ToLower(user.FirstName))
 	user.LastName = strings.TrimSpace(strings.ToLower(user.LastName))
@@ -36,10 +50,5 @@ func (user *User) Validate() *resterrors.RestErr {
 		return resterrors.NewBadRequestError("Invalid e-mail address.")
 	}
 
-	user.Password = strings.TrimSpace(user.Email)
-	if user.Password == "" {
-		return resterrors.NewBadRequestError("Invalid password.")
-	}
-
 	return nil
 }
@@ -0,0 +1,55 @@
+package users
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	anyETag      = "*"
+	weakETagMark = "W/"
+)
+
+// ETag returns the strong entity tag of the user, its quoted version.
+func (user *User) ETag() string {
+	return `"` + strconv.FormatInt(user.Version, 10) + `"`
+}
+
+// MatchesIfMatch checks the If-Match header against the user with the strong comparison. An empty
+// header always matches, so the precondition is only enforced when the client sends it.
+func (user *User) MatchesIfMatch(header string) bool {
+	if strings.TrimSpace(header) == "" {
+		return true
+	}
+
+	for _, etag := range parseETags(header) {
+		if etag == anyETag || etag == user.ETag() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MatchesIfNoneMatch checks if the If-None-Match header holds the user ETag with the weak
+// comparison, meaning the client representation is still current.
+func (user *User) MatchesIfNoneMatch(header string) bool {
+	for _, etag := range parseETags(header) {
+		if etag == anyETag || strings.TrimPrefix(etag, weakETagMark) == user.ETag() {
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseETags(header string) []string {
+	etags := make([]string, 0)
+	for _, etag := range strings.Split(header, ",") {
+		if etag = strings.TrimSpace(etag); etag != "" {
+			etags = append(etags, etag)
+		}
+	}
+
+	return etags
+}
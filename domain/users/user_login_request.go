@@ -4,4 +4,6 @@ package users
 type UserLoginRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+
+	ClientIP string `json:"-"`
 }
@@ -1,6 +1,11 @@
 package users
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
 
 // PublicUser is the struct to process the user when returning to requests without a permission
 type PublicUser struct {
@@ -17,6 +22,10 @@ type PrivateUser struct {
 	Email       string `json:"email"`
 	DateCreated string `json:"date_created"`
 	Status      string `json:"status"`
+
+	StatusReason        string `json:"status_reason,omitempty"`
+	DateStatusChanged   string `json:"date_status_changed"`
+	DatePasswordChanged string `json:"date_password_changed"`
 }
 
 // Marshall is a function used to process the user and return the user to marshalled to json (Public or Private)
@@ -43,3 +52,97 @@ func (users Users) Marshall(isPublic bool) []interface{} {
 	}
 	return result
 }
+
+// PublicSearchHit is the struct to process a full-text search result returning to requests without a permission
+type PublicSearchHit struct {
+	PublicUser
+	Score float64 `json:"score"`
+}
+
+// PrivateSearchHit is the struct to process a full-text search result returning to requests with a permission
+type PrivateSearchHit struct {
+	PrivateUser
+	SearchHit
+}
+
+// SearchResponse is the envelope of a page of the user search.
+type SearchResponse struct {
+	Results    []interface{} `json:"results"`
+	Total      int64         `json:"total"`
+	Limit      int           `json:"limit"`
+	Offset     int           `json:"offset"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// Marshall is a function used to process the page and return its users marshalled to json (Public or Private)
+func (result *SearchResult) Marshall(isPublic bool) SearchResponse {
+	results := result.Results.Marshall(isPublic)
+	if result.Hits != nil {
+		for index, user := range result.Results {
+			hit := result.Hits[user.ID]
+			if isPublic {
+				results[index] = PublicSearchHit{PublicUser: results[index].(PublicUser), Score: hit.Score}
+			} else {
+				results[index] = PrivateSearchHit{PrivateUser: results[index].(PrivateUser), SearchHit: hit}
+			}
+		}
+	}
+
+	return SearchResponse{
+		Results:    results,
+		Total:      result.Total,
+		Limit:      result.Limit,
+		Offset:     result.Offset,
+		NextCursor: result.NextCursor,
+	}
+}
+
+// BatchItemResponse is the struct to process the outcome of an user of the batch creation.
+type BatchItemResponse struct {
+	Index  int                 `json:"index"`
+	Status int                 `json:"status"`
+	User   interface{}         `json:"user,omitempty"`
+	Error  *resterrors.RestErr `json:"error,omitempty"`
+}
+
+// BatchCreateResponse is the envelope of the outcomes of the batch creation.
+type BatchCreateResponse struct {
+	Results []BatchItemResponse `json:"results"`
+	Created int                 `json:"created"`
+	Failed  int                 `json:"failed"`
+}
+
+// Marshall is a function used to process the batch and return its users marshalled to json (Public or Private)
+func (result *BatchCreateResult) Marshall(isPublic bool) BatchCreateResponse {
+	items := make([]BatchItemResponse, len(result.Results))
+	for index, item := range result.Results {
+		items[index] = BatchItemResponse{Index: item.Index}
+		if item.Error != nil {
+			items[index].Status = item.Error.Status
+			items[index].Error = item.Error
+			continue
+		}
+		items[index].Status = http.StatusCreated
+		items[index].User = item.User.Marshall(isPublic)
+	}
+
+	return BatchCreateResponse{
+		Results: items,
+		Created: result.Created,
+		Failed:  result.Failed,
+	}
+}
+
+// BatchLookupResponse is the envelope of the users found by the batch lookup.
+type BatchLookupResponse struct {
+	Results []interface{} `json:"results"`
+	Missing []int64       `json:"missing"`
+}
+
+// Marshall is a function used to process the lookup and return its users marshalled to json (Public or Private)
+func (result *BatchLookupResult) Marshall(isPublic bool) BatchLookupResponse {
+	return BatchLookupResponse{
+		Results: result.Results.Marshall(isPublic),
+		Missing: result.Missing,
+	}
+}
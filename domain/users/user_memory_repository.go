@@ -0,0 +1,433 @@
+package users
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+type memoryRepository struct {
+	mu     sync.RWMutex
+	lastID int64
+	users  map[int64]User
+	emails map[string]int64
+	index  TextIndex
+
+	statusesBeforeDelete map[int64]string
+
+	audit       []AuditEntry
+	lastAuditID int64
+}
+
+// NewMemoryRepository creates a thread-safe UserRepository keeping the users in memory,
+// used to run the API and its tests without a database.
+func NewMemoryRepository() UserRepository {
+	return &memoryRepository{
+		users:  make(map[int64]User),
+		emails: make(map[string]int64),
+		index:  NewInvertedIndex(),
+
+		statusesBeforeDelete: make(map[int64]string),
+	}
+}
+
+// Save the user in memory or return the RestErr.
+func (r *memoryRepository) Save(user *User, entry *AuditEntry) *resterrors.RestErr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.emails[user.Email]; exists {
+		return newEmailAlreadyExistsError(user.Email)
+	}
+
+	r.lastID++
+	user.ID = r.lastID
+	user.Version = 1
+
+	r.users[user.ID] = *user
+	r.emails[user.Email] = user.ID
+	r.index.Index(user)
+
+	entry.UserID = user.ID
+	r.appendAudit(entry)
+
+	return nil
+}
+
+// SaveAll saves the users in memory, failing the ones with an e-mail that already exists.
+func (r *memoryRepository) SaveAll(users Users, entries []*AuditEntry) ([]*resterrors.RestErr, *resterrors.RestErr) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	errs := make([]*resterrors.RestErr, len(users))
+	for index := range users {
+		user := &users[index]
+		if _, exists := r.emails[user.Email]; exists {
+			errs[index] = newEmailAlreadyExistsError(user.Email)
+			continue
+		}
+
+		r.lastID++
+		user.ID = r.lastID
+		user.Version = 1
+
+		r.users[user.ID] = *user
+		r.emails[user.Email] = user.ID
+		r.index.Index(user)
+
+		entries[index].UserID = user.ID
+		r.appendAudit(entries[index])
+	}
+
+	return errs, nil
+}
+
+// Get the user from memory or return a RestErr.
+func (r *memoryRepository) Get(user *User) *resterrors.RestErr {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	current, exists := r.users[user.ID]
+	if !exists || current.DateDeleted != "" {
+		return newUserNotFoundError(user.ID)
+	}
+
+	password := user.Password
+	*user = current
+	user.Password = password
+
+	return nil
+}
+
+// GetAll returns the users with the IDs from memory, without the password hashes.
+func (r *memoryRepository) GetAll(userIDs []int64) (Users, *resterrors.RestErr) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make(Users, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if user, exists := r.users[userID]; exists && user.DateDeleted == "" {
+			user.Password = ""
+			result = append(result, user)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+
+	return result, nil
+}
+
+// Update the user in memory or return the RestErr.
+func (r *memoryRepository) Update(user *User, entry *AuditEntry) *resterrors.RestErr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, exists := r.users[user.ID]
+	if !exists || current.DateDeleted != "" {
+		return newUserNotFoundError(user.ID)
+	}
+
+	if current.Version != user.Version {
+		return newVersionMismatchError(user.ID)
+	}
+
+	if ownerID, exists := r.emails[user.Email]; exists && ownerID != user.ID {
+		return newEmailAlreadyExistsError(user.Email)
+	}
+
+	delete(r.emails, current.Email)
+
+	current.FirstName = user.FirstName
+	current.LastName = user.LastName
+	current.Email = user.Email
+	current.Status = user.Status
+	current.StatusReason = user.StatusReason
+	current.DateStatusChanged = user.DateStatusChanged
+	current.Version++
+
+	r.users[user.ID] = current
+	r.emails[current.Email] = user.ID
+	r.index.Index(&current)
+	user.Version = current.Version
+	r.appendAudit(entry)
+
+	return nil
+}
+
+// UpdatePassword replaces the password hash and its change date of the user in memory or return the RestErr.
+func (r *memoryRepository) UpdatePassword(user *User, entry *AuditEntry) *resterrors.RestErr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, exists := r.users[user.ID]
+	if !exists || current.DateDeleted != "" {
+		return newUserNotFoundError(user.ID)
+	}
+
+	if current.Version != user.Version {
+		return newConcurrentChangeError(user.ID)
+	}
+
+	current.Password = user.Password
+	current.DatePasswordChanged = user.DatePasswordChanged
+	current.Version++
+	r.users[user.ID] = current
+	user.Version = current.Version
+	r.appendAudit(entry)
+
+	return nil
+}
+
+// UpdateStatus of the user in memory or return the RestErr.
+func (r *memoryRepository) UpdateStatus(user *User, entry *AuditEntry) *resterrors.RestErr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, exists := r.users[user.ID]
+	if !exists || current.DateDeleted != "" {
+		return newUserNotFoundError(user.ID)
+	}
+
+	if current.Version != user.Version {
+		return newConcurrentChangeError(user.ID)
+	}
+
+	current.Status = user.Status
+	current.StatusReason = user.StatusReason
+	current.DateStatusChanged = user.DateStatusChanged
+	current.Version++
+	r.users[user.ID] = current
+	user.Version = current.Version
+	r.appendAudit(entry)
+
+	return nil
+}
+
+// Delete marks the user as deleted in memory or return the RestErr.
+func (r *memoryRepository) Delete(user *User, entry *AuditEntry) *resterrors.RestErr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, exists := r.users[user.ID]
+	if !exists || current.DateDeleted != "" {
+		return newUserNotFoundError(user.ID)
+	}
+
+	if current.Version != user.Version {
+		return newVersionMismatchError(user.ID)
+	}
+
+	r.statusesBeforeDelete[user.ID] = current.Status
+	current.Status = StatusDeleted
+	current.DateDeleted = user.DateDeleted
+	current.Version++
+	r.users[user.ID] = current
+	r.index.Remove(user.ID)
+
+	user.Status = StatusDeleted
+	user.Version = current.Version
+	r.appendAudit(entry)
+
+	return nil
+}
+
+// Restore the deleted user in memory or return the RestErr.
+func (r *memoryRepository) Restore(user *User, entry *AuditEntry) *resterrors.RestErr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, exists := r.users[user.ID]
+	if !exists || current.DateDeleted == "" {
+		return newDeletedUserNotFoundError(user.ID)
+	}
+
+	before := current
+	current.Status = StatusPending
+	if status, kept := r.statusesBeforeDelete[user.ID]; kept {
+		current.Status = status
+	}
+	current.DateDeleted = ""
+	current.Version++
+	delete(r.statusesBeforeDelete, user.ID)
+	r.users[user.ID] = current
+	r.index.Index(&current)
+	entry.Diff(&before, &current)
+	r.appendAudit(entry)
+
+	*user = current
+	user.Password = ""
+
+	return nil
+}
+
+// Purge the users deleted before the date from memory or return the RestErr.
+func (r *memoryRepository) Purge(before string, entry *AuditEntry) (int64, *resterrors.RestErr) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var purged int64
+	for userID, user := range r.users {
+		if user.DateDeleted != "" && user.DateDeleted < before {
+			delete(r.emails, user.Email)
+			delete(r.users, userID)
+			delete(r.statusesBeforeDelete, userID)
+			purged++
+
+			purge := *entry
+			purge.UserID = userID
+			r.appendAudit(&purge)
+		}
+	}
+
+	return purged, nil
+}
+
+// Search returns the page of the users matching the validated request, without the password hashes.
+func (r *memoryRepository) Search(request SearchRequest) (*SearchResult, *resterrors.RestErr) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var scores map[int64]float64
+	if request.IsTextSearch() {
+		scores = r.index.Search(request.Terms())
+	}
+
+	matches := make(Users, 0)
+	for _, user := range r.users {
+		if _, scored := scores[user.ID]; user.DateDeleted == "" && (scores == nil || scored) && request.Matches(&user) {
+			user.Password = ""
+			matches = append(matches, user)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if request.Sort == SortRelevance {
+			scoreI, scoreJ := scores[matches[i].ID], scores[matches[j].ID]
+			if scoreI != scoreJ {
+				return (scoreI > scoreJ) == (request.Order == OrderDesc)
+			}
+			return matches[i].ID < matches[j].ID
+		}
+		return request.Less(&matches[i], &matches[j])
+	})
+
+	result := &SearchResult{
+		Results: make(Users, 0, request.Limit),
+		Total:   int64(len(matches)),
+		Limit:   request.Limit,
+		Offset:  request.Offset,
+	}
+
+	skipped := 0
+	for index := range matches {
+		if !request.IsAfterCursor(&matches[index]) {
+			continue
+		}
+		if skipped < request.Offset {
+			skipped++
+			continue
+		}
+		if len(result.Results) == request.Limit {
+			last := result.Results[len(result.Results)-1]
+			result.NextCursor = request.NextCursor(&last)
+			break
+		}
+		result.Results = append(result.Results, matches[index])
+	}
+
+	if scores != nil {
+		result.Hits = make(map[int64]SearchHit, len(result.Results))
+		for index := range result.Results {
+			user := &result.Results[index]
+			result.Hits[user.ID] = NewSearchHit(user, request.Terms(), scores[user.ID])
+		}
+	}
+
+	return result, nil
+}
+
+// Stream the users matching the validated request from a copy taken in memory, so the function
+// runs without holding the lock.
+func (r *memoryRepository) Stream(request SearchRequest, fn func(*User) error) *resterrors.RestErr {
+	r.mu.RLock()
+	var scores map[int64]float64
+	if request.IsTextSearch() {
+		scores = r.index.Search(request.Terms())
+	}
+
+	matches := make(Users, 0)
+	for _, user := range r.users {
+		if _, scored := scores[user.ID]; user.DateDeleted == "" && (scores == nil || scored) && request.Matches(&user) {
+			user.Password = ""
+			matches = append(matches, user)
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].ID < matches[j].ID })
+
+	for index := range matches {
+		if err := fn(&matches[index]); err != nil {
+			return newStreamError(err)
+		}
+	}
+
+	return nil
+}
+
+// GetAudit returns the page of the audit trail of the user from memory, newest first.
+func (r *memoryRepository) GetAudit(request AuditRequest) (*AuditResult, *resterrors.RestErr) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := &AuditResult{
+		Results: make([]AuditEntry, 0, request.Limit),
+		Limit:   request.Limit,
+		Offset:  request.Offset,
+	}
+
+	for index := len(r.audit) - 1; index >= 0; index-- {
+		if r.audit[index].UserID != request.UserID {
+			continue
+		}
+
+		if result.Total >= int64(request.Offset) && len(result.Results) < request.Limit {
+			result.Results = append(result.Results, r.audit[index])
+		}
+		result.Total++
+	}
+
+	return result, nil
+}
+
+// Audit appends the entry to the audit trail in memory.
+func (r *memoryRepository) Audit(entry *AuditEntry) *resterrors.RestErr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.appendAudit(entry)
+	return nil
+}
+
+// appendAudit stores a copy of the entry, the caller must hold the write lock.
+func (r *memoryRepository) appendAudit(entry *AuditEntry) {
+	r.lastAuditID++
+	entry.ID = r.lastAuditID
+	r.audit = append(r.audit, *entry)
+}
+
+// FindByEmail the user from memory with a e-mail, including the password hash.
+func (r *memoryRepository) FindByEmail(user *User) *resterrors.RestErr {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	userID, exists := r.emails[user.Email]
+	if !exists || r.users[userID].DateDeleted != "" {
+		return newInvalidCredentialsError()
+	}
+
+	*user = r.users[userID]
+
+	return nil
+}
@@ -0,0 +1,201 @@
+package users
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/migueloli/bookstore_users-api/utils/resterrorsutils"
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+const (
+	// MergePatchContentType is the media type of the JSON Merge Patch (RFC 7396).
+	MergePatchContentType = "application/merge-patch+json"
+	// JSONPatchContentType is the media type of the JSON Patch (RFC 6902).
+	JSONPatchContentType = "application/json-patch+json"
+)
+
+// patchableFields are the user fields a patch can change, by their JSON name. Clearing a field
+// sets it to the empty string, the user validation decides if it may stay empty.
+var patchableFields = map[string]func(*User) *string{
+	"first_name": func(user *User) *string { return &user.FirstName },
+	"last_name":  func(user *User) *string { return &user.LastName },
+	"email":      func(user *User) *string { return &user.Email },
+}
+
+// immutableFields are the user fields known to the API that a patch can't change.
+var immutableFields = map[string]bool{
+	"id":                    true,
+	"date_created":          true,
+	"status":                true,
+	"status_reason":         true,
+	"date_status_changed":   true,
+	"password":              true,
+	"date_password_changed": true,
+}
+
+// Patch is a partial change of the user, parsed from the request body.
+type Patch interface {
+	// Apply changes the user or returns the RestErr, leaving it untouched on failure.
+	Apply(*User) *resterrors.RestErr
+}
+
+// NewPatch parses the body by its content type, a JSON Merge Patch, also accepted as plain JSON,
+// or a JSON Patch, refusing the other media types with 415.
+func NewPatch(contentType string, body []byte) (Patch, *resterrors.RestErr) {
+	switch contentType {
+	case MergePatchContentType, "application/json":
+		return NewMergePatch(body)
+	case JSONPatchContentType:
+		return NewJSONPatch(body)
+	default:
+		return nil, resterrorsutils.NewUnsupportedMediaTypeError(fmt.Sprintf("Use %s or %s.", MergePatchContentType, JSONPatchContentType))
+	}
+}
+
+type mergePatch map[string]json.RawMessage
+
+// NewMergePatch parses the JSON Merge Patch, refusing with 422 the fields that are unknown or
+// immutable. A null value clears the field.
+func NewMergePatch(body []byte) (Patch, *resterrors.RestErr) {
+	var patch mergePatch
+	if err := json.Unmarshal(body, &patch); err != nil || patch == nil {
+		return nil, resterrors.NewBadRequestError("The merge patch should be a JSON object.")
+	}
+
+	for name, value := range patch {
+		if _, err := getPatchableField(name); err != nil {
+			return nil, err
+		}
+		if _, err := decodePatchValue(name, value); err != nil {
+			return nil, err
+		}
+	}
+
+	return patch, nil
+}
+
+// Apply sets every field of the merge patch on the user.
+func (p mergePatch) Apply(user *User) *resterrors.RestErr {
+	patched := *user
+	for name, value := range p {
+		field, _ := getPatchableField(name)
+		*field(&patched), _ = decodePatchValue(name, value)
+	}
+
+	*user = patched
+	return nil
+}
+
+// jsonPatchOperation is an operation of the JSON Patch.
+type jsonPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+type jsonPatch []jsonPatchOperation
+
+// NewJSONPatch parses the JSON Patch, refusing with 400 the malformed operations and with 422 the
+// paths to fields that are unknown or immutable. The paths are the top level JSON names of the user.
+func NewJSONPatch(body []byte) (Patch, *resterrors.RestErr) {
+	var patch jsonPatch
+	if err := json.Unmarshal(body, &patch); err != nil || patch == nil {
+		return nil, resterrors.NewBadRequestError("The JSON patch should be an array of operations.")
+	}
+
+	for index, operation := range patch {
+		switch operation.Op {
+		case "add", "replace", "test":
+			if operation.Value == nil {
+				return nil, resterrors.NewBadRequestError(fmt.Sprintf("Operation %d (%s) requires a value.", index, operation.Op))
+			}
+			if _, err := decodePatchPath(operation.Path); err != nil {
+				return nil, err
+			}
+			if _, err := decodePatchValue(operation.Path, operation.Value); err != nil {
+				return nil, err
+			}
+		case "remove":
+			if _, err := decodePatchPath(operation.Path); err != nil {
+				return nil, err
+			}
+		case "move", "copy":
+			if _, err := decodePatchPath(operation.From); err != nil {
+				return nil, err
+			}
+			if _, err := decodePatchPath(operation.Path); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, resterrors.NewBadRequestError(fmt.Sprintf("Operation %d has an invalid op %s.", index, operation.Op))
+		}
+	}
+
+	return patch, nil
+}
+
+// Apply runs the operations in order on a copy of the user, so a failed test leaves it untouched.
+func (p jsonPatch) Apply(user *User) *resterrors.RestErr {
+	patched := *user
+	for index, operation := range p {
+		field, _ := decodePatchPath(operation.Path)
+		switch operation.Op {
+		case "add", "replace":
+			*field(&patched), _ = decodePatchValue(operation.Path, operation.Value)
+		case "remove":
+			*field(&patched) = ""
+		case "test":
+			if expected, _ := decodePatchValue(operation.Path, operation.Value); *field(&patched) != expected {
+				return resterrorsutils.NewConflictError(fmt.Sprintf("Operation %d (test) failed on %s.", index, operation.Path))
+			}
+		case "move", "copy":
+			from, _ := decodePatchPath(operation.From)
+			value := *from(&patched)
+			if operation.Op == "move" {
+				*from(&patched) = ""
+			}
+			*field(&patched) = value
+		}
+	}
+
+	*user = patched
+	return nil
+}
+
+func decodePatchPath(path string) (func(*User) *string, *resterrors.RestErr) {
+	if !strings.HasPrefix(path, "/") || strings.Count(path, "/") != 1 {
+		return nil, resterrorsutils.NewUnprocessableEntityError(fmt.Sprintf("Path %s doesn't point to a field of the user.", path))
+	}
+
+	name := strings.NewReplacer("~1", "/", "~0", "~").Replace(path[1:])
+	return getPatchableField(name)
+}
+
+func getPatchableField(name string) (func(*User) *string, *resterrors.RestErr) {
+	if field, exists := patchableFields[name]; exists {
+		return field, nil
+	}
+
+	if immutableFields[name] {
+		return nil, resterrorsutils.NewUnprocessableEntityError(fmt.Sprintf("Field %s can't be changed.", name))
+	}
+
+	return nil, resterrorsutils.NewUnprocessableEntityError(fmt.Sprintf("Unknown field %s.", name))
+}
+
+func decodePatchValue(name string, value json.RawMessage) (string, *resterrors.RestErr) {
+	if bytes.Equal(bytes.TrimSpace(value), []byte("null")) {
+		return "", nil
+	}
+
+	var text string
+	if err := json.Unmarshal(value, &text); err != nil {
+		return "", resterrorsutils.NewUnprocessableEntityError(fmt.Sprintf("Field %s should be a string or null.", strings.TrimPrefix(name, "/")))
+	}
+
+	return text, nil
+}
@@ -0,0 +1,116 @@
+package users
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+func testPatchUser() User {
+	return User{ID: 1, FirstName: "first", LastName: "last", Email: "user@example.com", Status: StatusActive}
+}
+
+func statusOf(err *resterrors.RestErr) int {
+	if err == nil {
+		return http.StatusOK
+	}
+
+	return err.Status
+}
+
+func TestNewPatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		parseStatus int
+		applyStatus int
+		want        User
+	}{
+		// JSON Merge Patch
+		{name: "merge patch sets a field", contentType: MergePatchContentType, body: `{"first_name": "patched"}`,
+			want: User{FirstName: "patched", LastName: "last", Email: "user@example.com"}},
+		{name: "merge patch sets several fields", contentType: MergePatchContentType, body: `{"last_name": "patched", "email": "other@example.com"}`,
+			want: User{FirstName: "first", LastName: "patched", Email: "other@example.com"}},
+		{name: "merge patch null clears the field", contentType: MergePatchContentType, body: `{"last_name": null}`,
+			want: User{FirstName: "first", Email: "user@example.com"}},
+		{name: "merge patch empty object", contentType: MergePatchContentType, body: `{}`,
+			want: User{FirstName: "first", LastName: "last", Email: "user@example.com"}},
+		{name: "merge patch unknown field", contentType: MergePatchContentType, body: `{"nickname": "patched"}`, parseStatus: http.StatusUnprocessableEntity},
+		{name: "merge patch immutable field", contentType: MergePatchContentType, body: `{"status": "banned"}`, parseStatus: http.StatusUnprocessableEntity},
+		{name: "merge patch value not a string", contentType: MergePatchContentType, body: `{"first_name": 1}`, parseStatus: http.StatusUnprocessableEntity},
+		{name: "merge patch not an object", contentType: MergePatchContentType, body: `["first_name"]`, parseStatus: http.StatusBadRequest},
+		{name: "merge patch null body", contentType: MergePatchContentType, body: `null`, parseStatus: http.StatusBadRequest},
+
+		// Plain JSON falls back to the JSON Merge Patch.
+		{name: "plain json is a merge patch", contentType: "application/json", body: `{"first_name": "patched", "last_name": null}`,
+			want: User{FirstName: "patched", Email: "user@example.com"}},
+		{name: "plain json immutable field", contentType: "application/json", body: `{"id": 2}`, parseStatus: http.StatusUnprocessableEntity},
+		{name: "plain json isn't a json patch", contentType: "application/json", body: `[{"op": "remove", "path": "/last_name"}]`, parseStatus: http.StatusBadRequest},
+
+		// JSON Patch
+		{name: "json patch replace", contentType: JSONPatchContentType, body: `[{"op": "replace", "path": "/first_name", "value": "patched"}]`,
+			want: User{FirstName: "patched", LastName: "last", Email: "user@example.com"}},
+		{name: "json patch add", contentType: JSONPatchContentType, body: `[{"op": "add", "path": "/email", "value": "other@example.com"}]`,
+			want: User{FirstName: "first", LastName: "last", Email: "other@example.com"}},
+		{name: "json patch remove clears the field", contentType: JSONPatchContentType, body: `[{"op": "remove", "path": "/last_name"}]`,
+			want: User{FirstName: "first", Email: "user@example.com"}},
+		{name: "json patch replace with null clears the field", contentType: JSONPatchContentType, body: `[{"op": "replace", "path": "/first_name", "value": null}]`,
+			want: User{LastName: "last", Email: "user@example.com"}},
+		{name: "json patch move", contentType: JSONPatchContentType, body: `[{"op": "move", "from": "/first_name", "path": "/last_name"}]`,
+			want: User{LastName: "first", Email: "user@example.com"}},
+		{name: "json patch copy", contentType: JSONPatchContentType, body: `[{"op": "copy", "from": "/first_name", "path": "/last_name"}]`,
+			want: User{FirstName: "first", LastName: "first", Email: "user@example.com"}},
+		{name: "json patch test passes", contentType: JSONPatchContentType, body: `[{"op": "test", "path": "/last_name", "value": "last"}, {"op": "replace", "path": "/last_name", "value": "patched"}]`,
+			want: User{FirstName: "first", LastName: "patched", Email: "user@example.com"}},
+		{name: "json patch test fails", contentType: JSONPatchContentType, body: `[{"op": "replace", "path": "/first_name", "value": "patched"}, {"op": "test", "path": "/last_name", "value": "other"}]`,
+			applyStatus: http.StatusConflict},
+		{name: "json patch escaped path", contentType: JSONPatchContentType, body: `[{"op": "remove", "path": "/first~1name"}]`, parseStatus: http.StatusUnprocessableEntity},
+		{name: "json patch nested path", contentType: JSONPatchContentType, body: `[{"op": "remove", "path": "/first_name/0"}]`, parseStatus: http.StatusUnprocessableEntity},
+		{name: "json patch unknown path", contentType: JSONPatchContentType, body: `[{"op": "replace", "path": "/nickname", "value": "patched"}]`, parseStatus: http.StatusUnprocessableEntity},
+		{name: "json patch immutable path", contentType: JSONPatchContentType, body: `[{"op": "remove", "path": "/password"}]`, parseStatus: http.StatusUnprocessableEntity},
+		{name: "json patch immutable from", contentType: JSONPatchContentType, body: `[{"op": "copy", "from": "/status", "path": "/last_name"}]`, parseStatus: http.StatusUnprocessableEntity},
+		{name: "json patch missing value", contentType: JSONPatchContentType, body: `[{"op": "replace", "path": "/first_name"}]`, parseStatus: http.StatusBadRequest},
+		{name: "json patch invalid op", contentType: JSONPatchContentType, body: `[{"op": "merge", "path": "/first_name", "value": "patched"}]`, parseStatus: http.StatusBadRequest},
+		{name: "json patch not an array", contentType: JSONPatchContentType, body: `{"first_name": "patched"}`, parseStatus: http.StatusBadRequest},
+
+		{name: "unsupported media type", contentType: "text/plain", body: `{"first_name": "patched"}`, parseStatus: http.StatusUnsupportedMediaType},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			patch, err := NewPatch(test.contentType, []byte(test.body))
+			if want := statusOrOK(test.parseStatus); statusOf(err) != want {
+				t.Fatalf("parsing: got status %d, want %d", statusOf(err), want)
+			}
+			if err != nil {
+				return
+			}
+
+			user := testPatchUser()
+			err = patch.Apply(&user)
+			if want := statusOrOK(test.applyStatus); statusOf(err) != want {
+				t.Fatalf("applying: got status %d, want %d", statusOf(err), want)
+			}
+
+			want := test.want
+			if err != nil {
+				// A failed patch leaves the user untouched.
+				want = testPatchUser()
+			}
+			want.ID, want.Status = user.ID, user.Status
+			if user != want {
+				t.Errorf("got %+v, want %+v", user, want)
+			}
+		})
+	}
+}
+
+func statusOrOK(status int) int {
+	if status == 0 {
+		return http.StatusOK
+	}
+
+	return status
+}
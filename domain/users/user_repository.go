@@ -0,0 +1,82 @@
+package users
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/migueloli/bookstore_users-api/logger"
+	"github.com/migueloli/bookstore_users-api/utils/resterrorsutils"
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+// UserRepository is the persistence contract of the users domain. Every implementation must
+// keep the e-mail unique and return a not found RestErr for missing users. Deleted users keep
+// their e-mail but are missing for every method except Restore and Purge. Every mutation appends
+// its AuditEntry to the audit trail atomically with it, filling the entry ID and, on creation,
+// the user ID.
+type UserRepository interface {
+	Save(*User, *AuditEntry) *resterrors.RestErr
+	// SaveAll saves the users in a single transaction, returning the error of each one by its
+	// position. An e-mail that already exists only fails its user, any other error saves none.
+	// The entries are matched to the users by position.
+	SaveAll(Users, []*AuditEntry) ([]*resterrors.RestErr, *resterrors.RestErr)
+	Get(*User) *resterrors.RestErr
+	// GetAll returns the users with the IDs sorted by ID, leaving out the missing ones.
+	GetAll([]int64) (Users, *resterrors.RestErr)
+	// Update replaces the names, e-mail and status of the user if it still has the Version,
+	// returning a precondition failed RestErr otherwise. Every write increments the Version.
+	Update(*User, *AuditEntry) *resterrors.RestErr
+	// UpdatePassword and UpdateStatus only write the user if it still has the Version, returning a
+	// conflict RestErr otherwise, as the change was decided on the state read.
+	UpdatePassword(*User, *AuditEntry) *resterrors.RestErr
+	UpdateStatus(*User, *AuditEntry) *resterrors.RestErr
+	// Delete marks the user as deleted at the DateDeleted, keeping its status to be restored, if it
+	// still has the Version.
+	Delete(*User, *AuditEntry) *resterrors.RestErr
+	// Restore brings back the deleted user with the status it had. The changes of the entry are
+	// set by the repository, the only one knowing the deleted state.
+	Restore(*User, *AuditEntry) *resterrors.RestErr
+	// Purge removes for good the users deleted before the date, returning how many. The entry is
+	// appended once for each purged user, keeping their audit trail.
+	Purge(string, *AuditEntry) (int64, *resterrors.RestErr)
+	Search(SearchRequest) (*SearchResult, *resterrors.RestErr)
+	// Stream calls the function with every user matching the filters of the validated request,
+	// sorted by ID and without the password hash, ignoring the sort and page. It stops at the first
+	// error of the function.
+	Stream(SearchRequest, func(*User) error) *resterrors.RestErr
+	FindByEmail(*User) *resterrors.RestErr
+	// GetAudit returns the page of the audit trail of the user, newest first, even once purged.
+	GetAudit(AuditRequest) (*AuditResult, *resterrors.RestErr)
+	// Audit appends the entry of a change kept outside of the users, like the unlock of the login
+	// attempts, to the audit trail.
+	Audit(*AuditEntry) *resterrors.RestErr
+}
+
+func newEmailAlreadyExistsError(email string) *resterrors.RestErr {
+	return resterrors.NewBadRequestError(fmt.Sprintf("E-mail %s already exists.", email))
+}
+
+func newUserNotFoundError(userID int64) *resterrors.RestErr {
+	return resterrors.NewNotFoundError(fmt.Sprintf("User %d not found.", userID))
+}
+
+func newVersionMismatchError(userID int64) *resterrors.RestErr {
+	return resterrorsutils.NewPreconditionFailedError(fmt.Sprintf("User %d was modified by another request.", userID))
+}
+
+func newConcurrentChangeError(userID int64) *resterrors.RestErr {
+	return resterrorsutils.NewConflictError(fmt.Sprintf("User %d was changed by another request, try again.", userID))
+}
+
+func newDeletedUserNotFoundError(userID int64) *resterrors.RestErr {
+	return resterrors.NewNotFoundError(fmt.Sprintf("Deleted user %d not found.", userID))
+}
+
+func newStreamError(err error) *resterrors.RestErr {
+	logger.Error("Error when trying to stream users.", err)
+	return resterrors.NewInternalServerError("Error when trying to stream users.", errors.New("stream error"))
+}
+
+func newInvalidCredentialsError() *resterrors.RestErr {
+	return resterrors.NewNotFoundError("Invalid user credentials.")
+}
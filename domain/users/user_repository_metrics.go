@@ -0,0 +1,130 @@
+package users
+
+import (
+	"time"
+
+	"github.com/migueloli/bookstore_users-api/metrics"
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+const (
+	usersRepositoryName = "users"
+)
+
+type instrumentedRepository struct {
+	next UserRepository
+}
+
+// NewInstrumentedRepository wraps the UserRepository timing its queries by method and result.
+func NewInstrumentedRepository(next UserRepository) UserRepository {
+	return &instrumentedRepository{next: next}
+}
+
+func observeQuery(method string, start time.Time, err *resterrors.RestErr) {
+	metrics.QueryDuration.WithLabelValues(usersRepositoryName, method, metrics.Result(err)).Observe(time.Since(start).Seconds())
+}
+
+func (r *instrumentedRepository) Save(user *User, entry *AuditEntry) *resterrors.RestErr {
+	start := time.Now()
+	err := r.next.Save(user, entry)
+	observeQuery("Save", start, err)
+	return err
+}
+
+func (r *instrumentedRepository) SaveAll(users Users, entries []*AuditEntry) ([]*resterrors.RestErr, *resterrors.RestErr) {
+	start := time.Now()
+	result, err := r.next.SaveAll(users, entries)
+	observeQuery("SaveAll", start, err)
+	return result, err
+}
+
+func (r *instrumentedRepository) Get(user *User) *resterrors.RestErr {
+	start := time.Now()
+	err := r.next.Get(user)
+	observeQuery("Get", start, err)
+	return err
+}
+
+func (r *instrumentedRepository) GetAll(userIDs []int64) (Users, *resterrors.RestErr) {
+	start := time.Now()
+	result, err := r.next.GetAll(userIDs)
+	observeQuery("GetAll", start, err)
+	return result, err
+}
+
+func (r *instrumentedRepository) Update(user *User, entry *AuditEntry) *resterrors.RestErr {
+	start := time.Now()
+	err := r.next.Update(user, entry)
+	observeQuery("Update", start, err)
+	return err
+}
+
+func (r *instrumentedRepository) UpdatePassword(user *User, entry *AuditEntry) *resterrors.RestErr {
+	start := time.Now()
+	err := r.next.UpdatePassword(user, entry)
+	observeQuery("UpdatePassword", start, err)
+	return err
+}
+
+func (r *instrumentedRepository) UpdateStatus(user *User, entry *AuditEntry) *resterrors.RestErr {
+	start := time.Now()
+	err := r.next.UpdateStatus(user, entry)
+	observeQuery("UpdateStatus", start, err)
+	return err
+}
+
+func (r *instrumentedRepository) Delete(user *User, entry *AuditEntry) *resterrors.RestErr {
+	start := time.Now()
+	err := r.next.Delete(user, entry)
+	observeQuery("Delete", start, err)
+	return err
+}
+
+func (r *instrumentedRepository) Restore(user *User, entry *AuditEntry) *resterrors.RestErr {
+	start := time.Now()
+	err := r.next.Restore(user, entry)
+	observeQuery("Restore", start, err)
+	return err
+}
+
+func (r *instrumentedRepository) Purge(before string, entry *AuditEntry) (int64, *resterrors.RestErr) {
+	start := time.Now()
+	result, err := r.next.Purge(before, entry)
+	observeQuery("Purge", start, err)
+	return result, err
+}
+
+func (r *instrumentedRepository) Search(request SearchRequest) (*SearchResult, *resterrors.RestErr) {
+	start := time.Now()
+	result, err := r.next.Search(request)
+	observeQuery("Search", start, err)
+	return result, err
+}
+
+func (r *instrumentedRepository) Stream(request SearchRequest, fn func(*User) error) *resterrors.RestErr {
+	start := time.Now()
+	err := r.next.Stream(request, fn)
+	observeQuery("Stream", start, err)
+	return err
+}
+
+func (r *instrumentedRepository) FindByEmail(user *User) *resterrors.RestErr {
+	start := time.Now()
+	err := r.next.FindByEmail(user)
+	observeQuery("FindByEmail", start, err)
+	return err
+}
+
+func (r *instrumentedRepository) GetAudit(request AuditRequest) (*AuditResult, *resterrors.RestErr) {
+	start := time.Now()
+	result, err := r.next.GetAudit(request)
+	observeQuery("GetAudit", start, err)
+	return result, err
+}
+
+func (r *instrumentedRepository) Audit(entry *AuditEntry) *resterrors.RestErr {
+	start := time.Now()
+	err := r.next.Audit(entry)
+	observeQuery("Audit", start, err)
+	return err
+}
@@ -0,0 +1,125 @@
+package users
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+// Roles that can be granted to the users.
+const (
+	RoleUser    = "user"
+	RoleSupport = "support"
+	RoleAdmin   = "admin"
+)
+
+// Permissions checked by the application, granted through the roles.
+const (
+	PermissionUsersReadPrivate = "users:read_private"
+	PermissionUsersSearch      = "users:search"
+	PermissionUsersUpdate      = "users:update"
+	PermissionUsersDelete      = "users:delete"
+	PermissionUsersRestore     = "users:restore"
+	PermissionUsersUnlock      = "users:unlock"
+	PermissionUsersModerate    = "users:moderate"
+	PermissionUsersImport      = "users:import"
+	PermissionUsersExport      = "users:export"
+	PermissionUsersAudit       = "users:audit"
+	PermissionRolesManage      = "roles:manage"
+)
+
+// RolePermissions lists the permissions held by each role. RoleUser is implicit for every user
+// and only allows what the owner of an account can already do.
+var RolePermissions = map[string][]string{
+	RoleUser: {},
+	RoleSupport: {
+		PermissionUsersReadPrivate,
+		PermissionUsersSearch,
+		PermissionUsersUnlock,
+	},
+	RoleAdmin: {
+		PermissionUsersReadPrivate,
+		PermissionUsersSearch,
+		PermissionUsersUpdate,
+		PermissionUsersDelete,
+		PermissionUsersRestore,
+		PermissionUsersUnlock,
+		PermissionUsersModerate,
+		PermissionUsersImport,
+		PermissionUsersExport,
+		PermissionUsersAudit,
+		PermissionRolesManage,
+	},
+}
+
+// UserRoles is the struct of the roles held by an user.
+type UserRoles struct {
+	UserID int64    `json:"user_id"`
+	Roles  []string `json:"roles"`
+}
+
+// RoleRepository is the persistence contract of the roles granted to the users. RoleUser is
+// never stored. Every change appends its AuditEntry to the audit trail of the users atomically
+// with it.
+type RoleRepository interface {
+	// GetRoles returns the roles granted to the user, sorted by name.
+	GetRoles(int64) ([]string, *resterrors.RestErr)
+	// Grant stores the role for the user at the given date. Granting a held role is a no-op and
+	// isn't audited.
+	Grant(int64, string, string, *AuditEntry) *resterrors.RestErr
+	// Revoke removes the role from the user or returns a not found RestErr.
+	Revoke(int64, string, *AuditEntry) *resterrors.RestErr
+}
+
+// ValidateRole checks that the role exists and can be granted or revoked.
+func ValidateRole(role string) *resterrors.RestErr {
+	if role == RoleUser {
+		return resterrors.NewBadRequestError("The user role is implicit and can't be granted or revoked.")
+	}
+
+	if _, exists := RolePermissions[role]; !exists {
+		return resterrors.NewBadRequestError(fmt.Sprintf("Unknown role %s.", role))
+	}
+
+	return nil
+}
+
+// HasPermission checks if any of the roles holds the permission.
+func HasPermission(roles []string, permission string) bool {
+	for _, role := range roles {
+		for _, granted := range RolePermissions[role] {
+			if granted == permission {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// NewUserRoles returns the roles of the user including the implicit RoleUser.
+func NewUserRoles(userID int64, granted []string) UserRoles {
+	roles := append([]string{RoleUser}, granted...)
+	sort.Strings(roles)
+
+	return UserRoles{UserID: userID, Roles: roles}
+}
+
+// NewRoleAuditEntry creates the entry of the role granted to or revoked from the user by the actor.
+func NewRoleAuditEntry(actor AuditActor, action string, userID int64, role string) *AuditEntry {
+	entry := NewAuditEntry(actor, action, userID)
+	change := AuditChange{Field: "role"}
+	if action == AuditActionRoleGrant {
+		change.After = role
+	} else {
+		change.Before = role
+	}
+	entry.Changes = append(entry.Changes, change)
+
+	return entry
+}
+
+func newRoleNotFoundError(userID int64, role string) *resterrors.RestErr {
+	return resterrors.NewNotFoundError(fmt.Sprintf("User %d doesn't hold the role %s.", userID, role))
+}
@@ -0,0 +1,128 @@
+package users
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/migueloli/bookstore_users-api/logger"
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+const (
+	queryGetUserRoles   = "SELECT role FROM user_roles WHERE user_id = ? ORDER BY role;"
+	queryGrantUserRole  = "INSERT IGNORE INTO user_roles(user_id, role, date_created) VALUES (?, ?, ?);"
+	queryRevokeUserRole = "DELETE FROM user_roles WHERE user_id = ? AND role = ?;"
+)
+
+type roleMySQLRepository struct {
+	client *sql.DB
+}
+
+// NewRoleMySQLRepository creates the RoleRepository backed by the given MySQL client.
+func NewRoleMySQLRepository(client *sql.DB) RoleRepository {
+	return &roleMySQLRepository{client: client}
+}
+
+// GetRoles of the user from the database or return the RestErr.
+func (r *roleMySQLRepository) GetRoles(userID int64) ([]string, *resterrors.RestErr) {
+	stmt, err := r.client.Prepare(queryGetUserRoles)
+	if err != nil {
+		logger.Error("Error when trying to prepare the get user roles statement.", err)
+		return nil, resterrors.NewInternalServerError("Error when trying to prepare the get user roles statement.", errors.New("database error"))
+	}
+
+	defer stmt.Close()
+
+	rows, err := stmt.Query(userID)
+	if err != nil {
+		logger.Error("Error when trying to get user roles.", err)
+		return nil, resterrors.NewInternalServerError("Error when trying to get user roles.", errors.New("database error"))
+	}
+
+	defer rows.Close()
+
+	roles := make([]string, 0)
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			logger.Error("Error when trying to scan user role row.", err)
+			return nil, resterrors.NewInternalServerError("Error when trying to scan user role row.", errors.New("database error"))
+		}
+		roles = append(roles, role)
+	}
+
+	if err := rows.Err(); err != nil {
+		logger.Error("Error when trying to get user roles.", err)
+		return nil, resterrors.NewInternalServerError("Error when trying to get user roles.", errors.New("database error"))
+	}
+
+	return roles, nil
+}
+
+// Grant the role to the user in the database or return the RestErr.
+func (r *roleMySQLRepository) Grant(userID int64, role string, now string, entry *AuditEntry) *resterrors.RestErr {
+	_, err := r.withAudit(entry, queryGrantUserRole, "grant user role", userID, role, now)
+	return err
+}
+
+// Revoke the role from the user in the database or return the RestErr.
+func (r *roleMySQLRepository) Revoke(userID int64, role string, entry *AuditEntry) *resterrors.RestErr {
+	changed, err := r.withAudit(entry, queryRevokeUserRole, "revoke user role", userID, role)
+	if err != nil {
+		return err
+	}
+
+	if !changed {
+		return newRoleNotFoundError(userID, role)
+	}
+
+	return nil
+}
+
+// withAudit runs the role change and appends the entry to the audit trail in a single
+// transaction, returning if any row changed. Nothing is stored when no row changed.
+func (r *roleMySQLRepository) withAudit(entry *AuditEntry, query string, operation string, args ...interface{}) (bool, *resterrors.RestErr) {
+	tx, err := r.client.Begin()
+	if err != nil {
+		logger.Error("Error when trying to begin the user role transaction.", err)
+		return false, resterrors.NewInternalServerError("Error when trying to begin the user role transaction.", errors.New("database error"))
+	}
+
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error when trying to prepare the %s statement.", operation), err)
+		return false, resterrors.NewInternalServerError(fmt.Sprintf("Error when trying to prepare the %s statement.", operation), errors.New("database error"))
+	}
+
+	defer stmt.Close()
+
+	result, err := stmt.Exec(args...)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error when trying to %s.", operation), err)
+		return false, resterrors.NewInternalServerError(fmt.Sprintf("Error when trying to %s.", operation), errors.New("database error"))
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		logger.Error(fmt.Sprintf("Error when trying to get the rows affected to %s.", operation), err)
+		return false, resterrors.NewInternalServerError(fmt.Sprintf("Error when trying to %s.", operation), errors.New("database error"))
+	}
+
+	if affected == 0 {
+		return false, nil
+	}
+
+	if restErr := insertAudit(tx, entry); restErr != nil {
+		return false, restErr
+	}
+
+	if err := tx.Commit(); err != nil {
+		logger.Error("Error when trying to commit the user role transaction.", err)
+		return false, resterrors.NewInternalServerError("Error when trying to commit the user role transaction.", errors.New("database error"))
+	}
+
+	return true, nil
+}
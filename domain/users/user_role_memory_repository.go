@@ -0,0 +1,75 @@
+package users
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+type roleMemoryRepository struct {
+	mu    sync.Mutex
+	roles map[int64]map[string]string
+	trail UserRepository
+}
+
+// NewRoleMemoryRepository creates a thread-safe RoleRepository keeping the roles in memory and
+// appending the changes to the audit trail of the users repository.
+func NewRoleMemoryRepository(usersRepository UserRepository) RoleRepository {
+	return &roleMemoryRepository{
+		roles: make(map[int64]map[string]string),
+		trail: usersRepository,
+	}
+}
+
+// GetRoles of the user from memory or return the RestErr.
+func (r *roleMemoryRepository) GetRoles(userID int64) ([]string, *resterrors.RestErr) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	roles := make([]string, 0, len(r.roles[userID]))
+	for role := range r.roles[userID] {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
+	return roles, nil
+}
+
+// Grant the role to the user in memory or return the RestErr.
+func (r *roleMemoryRepository) Grant(userID int64, role string, now string, entry *AuditEntry) *resterrors.RestErr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.roles[userID][role]; exists {
+		return nil
+	}
+
+	if err := r.trail.Audit(entry); err != nil {
+		return err
+	}
+
+	if r.roles[userID] == nil {
+		r.roles[userID] = make(map[string]string)
+	}
+	r.roles[userID][role] = now
+
+	return nil
+}
+
+// Revoke the role from the user in memory or return the RestErr.
+func (r *roleMemoryRepository) Revoke(userID int64, role string, entry *AuditEntry) *resterrors.RestErr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.roles[userID][role]; !exists {
+		return newRoleNotFoundError(userID, role)
+	}
+
+	if err := r.trail.Audit(entry); err != nil {
+		return err
+	}
+	delete(r.roles[userID], role)
+
+	return nil
+}
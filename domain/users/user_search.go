@@ -0,0 +1,276 @@
+package users
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/migueloli/bookstore_users-api/utils/dateutils"
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+const (
+	// DefaultSearchLimit is the page size used when the search doesn't inform one.
+	DefaultSearchLimit = 20
+	// MaxSearchLimit is the biggest page size accepted by the search.
+	MaxSearchLimit = 100
+
+	// SortID orders the search by the user ID.
+	SortID = "id"
+	// SortDateCreated orders the search by the creation date, then by ID.
+	SortDateCreated = "date_created"
+	// SortLastName orders the search by the last name, then by ID.
+	SortLastName = "last_name"
+	// SortRelevance orders the full-text search by the score of the matches, then by ID.
+	SortRelevance = "relevance"
+
+	// OrderAsc is the ascending search order.
+	OrderAsc = "asc"
+	// OrderDesc is the descending search order.
+	OrderDesc = "desc"
+)
+
+// SearchRequest is the struct of the filters, sort and page of the user search. Filters left
+// empty match every user. Cursor and Offset are mutually exclusive. Query is the full-text search
+// on the names and e-mail, sorted by relevance unless another sort is given.
+type SearchRequest struct {
+	Query       string
+	Status      string
+	EmailDomain string
+	NamePrefix  string
+	CreatedFrom string
+	CreatedTo   string
+
+	Sort   string
+	Order  string
+	Limit  int
+	Offset int
+	Cursor string
+
+	terms []string
+	after *searchCursor
+}
+
+// SearchResult is a page of the user search.
+type SearchResult struct {
+	Results    Users
+	Total      int64
+	Limit      int
+	Offset     int
+	NextCursor string
+
+	// Hits holds the relevance and highlights of the results of a full-text search by user ID.
+	Hits map[int64]SearchHit
+}
+
+// searchCursor points to the last user of a page, so the next one starts right after it even
+// when users are created or deleted in between.
+type searchCursor struct {
+	Sort  string `json:"s"`
+	Order string `json:"o"`
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+// Validate normalizes the search, applying the defaults and checking the filters, sort and page.
+func (r *SearchRequest) Validate() *resterrors.RestErr {
+	r.terms = nil
+	if r.Query = strings.TrimSpace(r.Query); r.Query != "" {
+		if r.terms = TokenizeText(r.Query); len(r.terms) == 0 {
+			return resterrors.NewBadRequestError("The search query should have letters or digits.")
+		}
+	}
+
+	r.Status = strings.TrimSpace(strings.ToLower(r.Status))
+	r.EmailDomain = strings.TrimPrefix(strings.TrimSpace(strings.ToLower(r.EmailDomain)), "@")
+	r.NamePrefix = strings.TrimSpace(strings.ToLower(r.NamePrefix))
+
+	if r.CreatedFrom != "" {
+		from, _, err := dateutils.ParseAPIString(r.CreatedFrom)
+		if err != nil {
+			return resterrors.NewBadRequestError("Invalid created_from date.")
+		}
+		r.CreatedFrom = dateutils.GetDBString(from)
+	}
+
+	if r.CreatedTo != "" {
+		to, isDay, err := dateutils.ParseAPIString(r.CreatedTo)
+		if err != nil {
+			return resterrors.NewBadRequestError("Invalid created_to date.")
+		}
+		if isDay {
+			to = to.Add(24*time.Hour - time.Second)
+		}
+		r.CreatedTo = dateutils.GetDBString(to)
+	}
+
+	r.Sort = strings.TrimSpace(strings.ToLower(r.Sort))
+	switch r.Sort {
+	case "":
+		r.Sort = SortID
+		if r.IsTextSearch() {
+			r.Sort = SortRelevance
+		}
+	case SortID, SortDateCreated, SortLastName:
+	case SortRelevance:
+		if !r.IsTextSearch() {
+			return resterrors.NewBadRequestError("The relevance sort requires a search query.")
+		}
+	default:
+		return resterrors.NewBadRequestError(fmt.Sprintf("Invalid sort %s, use %s, %s, %s or %s.", r.Sort, SortRelevance, SortID, SortDateCreated, SortLastName))
+	}
+
+	r.Order = strings.TrimSpace(strings.ToLower(r.Order))
+	switch r.Order {
+	case "":
+		r.Order = OrderAsc
+		if r.Sort == SortRelevance {
+			r.Order = OrderDesc
+		}
+	case OrderAsc, OrderDesc:
+	default:
+		return resterrors.NewBadRequestError(fmt.Sprintf("Invalid order %s, use %s or %s.", r.Order, OrderAsc, OrderDesc))
+	}
+
+	if r.Limit == 0 {
+		r.Limit = DefaultSearchLimit
+	}
+	if r.Limit < 0 || r.Limit > MaxSearchLimit {
+		return resterrors.NewBadRequestError(fmt.Sprintf("Limit should be between 1 and %d.", MaxSearchLimit))
+	}
+
+	if r.Offset < 0 {
+		return resterrors.NewBadRequestError("Offset should not be negative.")
+	}
+
+	r.after = nil
+	if r.Cursor != "" {
+		if r.Offset != 0 {
+			return resterrors.NewBadRequestError("Cursor and offset can't be used together.")
+		}
+
+		if r.Sort == SortRelevance {
+			return resterrors.NewBadRequestError("The relevance sort only supports offset pagination.")
+		}
+
+		cursor, err := decodeSearchCursor(r.Cursor)
+		if err != nil || cursor.Sort != r.Sort || cursor.Order != r.Order {
+			return resterrors.NewBadRequestError("Invalid cursor for the given sort and order.")
+		}
+		r.after = cursor
+	}
+
+	return nil
+}
+
+// IsTextSearch checks if the search has a full-text query.
+func (r *SearchRequest) IsTextSearch() bool {
+	return len(r.terms) > 0
+}
+
+// Terms returns the terms of the full-text query.
+func (r *SearchRequest) Terms() []string {
+	return r.terms
+}
+
+// Matches checks if the user passes the filters of the search, ignoring the page.
+func (r *SearchRequest) Matches(user *User) bool {
+	if r.Status != "" && user.Status != r.Status {
+		return false
+	}
+
+	if r.EmailDomain != "" && !strings.HasSuffix(user.Email, "@"+r.EmailDomain) {
+		return false
+	}
+
+	if r.NamePrefix != "" && !strings.HasPrefix(user.FirstName, r.NamePrefix) && !strings.HasPrefix(user.LastName, r.NamePrefix) {
+		return false
+	}
+
+	if r.CreatedFrom != "" && user.DateCreated < r.CreatedFrom {
+		return false
+	}
+
+	if r.CreatedTo != "" && user.DateCreated > r.CreatedTo {
+		return false
+	}
+
+	return true
+}
+
+// Less checks if the user a comes before the user b in the search sort and order.
+func (r *SearchRequest) Less(a *User, b *User) bool {
+	valueA, valueB := r.sortValue(a), r.sortValue(b)
+	if valueA == valueB {
+		if r.Order == OrderDesc {
+			return a.ID > b.ID
+		}
+		return a.ID < b.ID
+	}
+
+	if r.Order == OrderDesc {
+		return valueA > valueB
+	}
+	return valueA < valueB
+}
+
+// IsAfterCursor checks if the user comes after the cursor of the search, always true without one.
+func (r *SearchRequest) IsAfterCursor(user *User) bool {
+	if r.after == nil {
+		return true
+	}
+
+	return r.Less(&User{ID: r.after.ID, DateCreated: r.after.Value, LastName: r.after.Value}, user)
+}
+
+// NextCursor builds the cursor of the page ending with the user, empty for the relevance sort.
+func (r *SearchRequest) NextCursor(user *User) string {
+	if r.Sort == SortRelevance {
+		return ""
+	}
+
+	encoded, _ := json.Marshal(searchCursor{
+		Sort:  r.Sort,
+		Order: r.Order,
+		Value: r.sortValue(user),
+		ID:    user.ID,
+	})
+
+	return base64.RawURLEncoding.EncodeToString(encoded)
+}
+
+// CursorValue returns the sort value and the user ID of the cursor of the search.
+func (r *SearchRequest) CursorValue() (string, int64, bool) {
+	if r.after == nil {
+		return "", 0, false
+	}
+
+	return r.after.Value, r.after.ID, true
+}
+
+func (r *SearchRequest) sortValue(user *User) string {
+	switch r.Sort {
+	case SortDateCreated:
+		return user.DateCreated
+	case SortLastName:
+		return user.LastName
+	default:
+		return ""
+	}
+}
+
+func decodeSearchCursor(value string) (*searchCursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor searchCursor
+	if err := json.Unmarshal(decoded, &cursor); err != nil {
+		return nil, err
+	}
+
+	return &cursor, nil
+}
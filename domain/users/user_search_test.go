@@ -0,0 +1,161 @@
+package users
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// newTestSearchRepository creates the memory repository with the users, in order, so their IDs
+// start at 1.
+func newTestSearchRepository(t *testing.T, users ...User) UserRepository {
+	t.Helper()
+
+	repository := NewMemoryRepository()
+	for index := range users {
+		user := users[index]
+		user.Status = StatusActive
+		if user.DateCreated == "" {
+			user.DateCreated = fmt.Sprintf("2020-01-%02d 00:00:00", index+1)
+		}
+		if err := repository.Save(&user, NewAuditEntry(SystemActor, AuditActionCreate, 0)); err != nil {
+			t.Fatalf("saving %s: %s", user.Email, err.Message)
+		}
+	}
+
+	return repository
+}
+
+func idsOf(users Users) []int64 {
+	ids := make([]int64, 0, len(users))
+	for _, user := range users {
+		ids = append(ids, user.ID)
+	}
+
+	return ids
+}
+
+func TestSearchCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		sort  string
+		order string
+		user  User
+		value string
+	}{
+		{sort: SortID, order: OrderAsc, user: User{ID: 7}, value: ""},
+		{sort: SortDateCreated, order: OrderDesc, user: User{ID: 7, DateCreated: "2020-01-02 03:04:05"}, value: "2020-01-02 03:04:05"},
+		{sort: SortLastName, order: OrderAsc, user: User{ID: 7, LastName: "o'neil"}, value: "o'neil"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.sort+" "+test.order, func(t *testing.T) {
+			request := SearchRequest{Sort: test.sort, Order: test.order}
+			if err := request.Validate(); err != nil {
+				t.Fatalf("validating: %s", err.Message)
+			}
+
+			next := SearchRequest{Sort: test.sort, Order: test.order, Cursor: request.NextCursor(&test.user)}
+			if err := next.Validate(); err != nil {
+				t.Fatalf("validating the cursor: %s", err.Message)
+			}
+
+			value, id, ok := next.CursorValue()
+			if !ok || value != test.value || id != test.user.ID {
+				t.Errorf("got %q, %d, %t, want %q, %d, true", value, id, ok, test.value, test.user.ID)
+			}
+		})
+	}
+}
+
+func TestSearchValidatePage(t *testing.T) {
+	cursor := (&SearchRequest{Sort: SortID, Order: OrderAsc}).NextCursor(&User{ID: 1})
+
+	tests := []struct {
+		name    string
+		request SearchRequest
+		status  int
+		sort    string
+		order   string
+	}{
+		{name: "defaults", request: SearchRequest{}, status: http.StatusOK, sort: SortID, order: OrderAsc},
+		{name: "query sorts by relevance", request: SearchRequest{Query: "ann"}, status: http.StatusOK, sort: SortRelevance, order: OrderDesc},
+		{name: "query with another sort", request: SearchRequest{Query: "ann", Sort: SortLastName}, status: http.StatusOK, sort: SortLastName, order: OrderAsc},
+		{name: "relevance with an offset", request: SearchRequest{Query: "ann", Offset: 20}, status: http.StatusOK, sort: SortRelevance, order: OrderDesc},
+		{name: "cursor", request: SearchRequest{Cursor: cursor}, status: http.StatusOK, sort: SortID, order: OrderAsc},
+		{name: "cursor and offset", request: SearchRequest{Cursor: cursor, Offset: 20}, status: http.StatusBadRequest},
+		{name: "relevance with a cursor", request: SearchRequest{Query: "ann", Cursor: cursor}, status: http.StatusBadRequest},
+		{name: "relevance without a query", request: SearchRequest{Sort: SortRelevance}, status: http.StatusBadRequest},
+		{name: "cursor of another order", request: SearchRequest{Order: OrderDesc, Cursor: cursor}, status: http.StatusBadRequest},
+		{name: "cursor of another sort", request: SearchRequest{Sort: SortLastName, Cursor: cursor}, status: http.StatusBadRequest},
+		{name: "cursor not base64", request: SearchRequest{Cursor: "not a cursor"}, status: http.StatusBadRequest},
+		{name: "cursor not json", request: SearchRequest{Cursor: "bm90IGpzb24"}, status: http.StatusBadRequest},
+		{name: "query without terms", request: SearchRequest{Query: "@."}, status: http.StatusBadRequest},
+		{name: "negative offset", request: SearchRequest{Offset: -1}, status: http.StatusBadRequest},
+		{name: "limit too big", request: SearchRequest{Limit: MaxSearchLimit + 1}, status: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.request.Validate()
+			if statusOf(err) != test.status {
+				t.Fatalf("got status %d, want %d", statusOf(err), test.status)
+			}
+			if err != nil {
+				return
+			}
+
+			if test.request.Sort != test.sort || test.request.Order != test.order {
+				t.Errorf("got sort %s %s, want %s %s", test.request.Sort, test.request.Order, test.sort, test.order)
+			}
+		})
+	}
+}
+
+func TestSearchCursorPages(t *testing.T) {
+	repository := newTestSearchRepository(t,
+		User{FirstName: "ann", LastName: "smith", Email: "ann@example.com"},
+		User{FirstName: "bob", LastName: "jones", Email: "bob@example.com"},
+		User{FirstName: "cid", LastName: "smith", Email: "cid@example.com"},
+		User{FirstName: "dan", LastName: "adams", Email: "dan@example.com"},
+		User{FirstName: "eve", LastName: "jones", Email: "eve@example.com"},
+	)
+
+	tests := []struct {
+		sort  string
+		order string
+		want  []int64
+	}{
+		{sort: SortID, order: OrderAsc, want: []int64{1, 2, 3, 4, 5}},
+		{sort: SortID, order: OrderDesc, want: []int64{5, 4, 3, 2, 1}},
+		{sort: SortLastName, order: OrderAsc, want: []int64{4, 2, 5, 1, 3}},
+		{sort: SortLastName, order: OrderDesc, want: []int64{3, 1, 5, 2, 4}},
+		{sort: SortDateCreated, order: OrderDesc, want: []int64{5, 4, 3, 2, 1}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.sort+" "+test.order, func(t *testing.T) {
+			got := make([]int64, 0)
+			cursor := ""
+			for page := 0; page < len(test.want); page++ {
+				request := SearchRequest{Sort: test.sort, Order: test.order, Limit: 2, Cursor: cursor}
+				if err := request.Validate(); err != nil {
+					t.Fatalf("validating page %d: %s", page, err.Message)
+				}
+
+				result, err := repository.Search(request)
+				if err != nil {
+					t.Fatalf("searching page %d: %s", page, err.Message)
+				}
+				got = append(got, idsOf(result.Results)...)
+
+				if cursor = result.NextCursor; cursor == "" {
+					break
+				}
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package users
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/migueloli/bookstore_users-api/utils/resterrorsutils"
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+// Operations changing the user status.
+const (
+	OperationVerify     = "verify"
+	OperationActivate   = "activate"
+	OperationSuspend    = "suspend"
+	OperationBan        = "ban"
+	OperationReactivate = "reactivate"
+	OperationDelete     = "delete"
+)
+
+const maxStatusReasonLength = 255
+
+// StatusTransition is an operation moving the user from any of the From statuses to the To one.
+type StatusTransition struct {
+	Operation string
+	From      []string
+	To        string
+}
+
+// StatusTransitions is the state machine of the user status. Restoring a deleted user is left
+// out, it goes back to the status held before the deletion.
+var StatusTransitions = []StatusTransition{
+	{Operation: OperationVerify, From: []string{StatusPending}, To: StatusActive},
+	{Operation: OperationActivate, From: []string{StatusPending}, To: StatusActive},
+	{Operation: OperationSuspend, From: []string{StatusActive}, To: StatusSuspended},
+	{Operation: OperationBan, From: []string{StatusPending, StatusActive, StatusSuspended}, To: StatusBanned},
+	{Operation: OperationReactivate, From: []string{StatusSuspended, StatusBanned}, To: StatusActive},
+	{Operation: OperationDelete, From: []string{StatusPending, StatusActive, StatusSuspended, StatusBanned}, To: StatusDeleted},
+}
+
+// StatusChangeRequest is the struct to request an admin operation on the user status.
+type StatusChangeRequest struct {
+	Reason string `json:"reason"`
+}
+
+// Validate checks that the operation informs why it is done.
+func (r *StatusChangeRequest) Validate() *resterrors.RestErr {
+	r.Reason = strings.TrimSpace(r.Reason)
+	if r.Reason == "" {
+		return resterrors.NewBadRequestError("The reason of the status change is required.")
+	}
+
+	if len(r.Reason) > maxStatusReasonLength {
+		return resterrors.NewBadRequestError(fmt.Sprintf("The reason of the status change should have at most %d characters.", maxStatusReasonLength))
+	}
+
+	return nil
+}
+
+// NextStatus returns the status reached by applying the operation to the user or a conflict
+// RestErr when the state machine doesn't allow it.
+func NextStatus(from string, operation string) (string, *resterrors.RestErr) {
+	for _, transition := range StatusTransitions {
+		if transition.Operation != operation {
+			continue
+		}
+
+		for _, allowed := range transition.From {
+			if allowed == from {
+				return transition.To, nil
+			}
+		}
+
+		return "", resterrorsutils.NewConflictError(fmt.Sprintf("Can't %s an user with status %s.", operation, from))
+	}
+
+	return "", resterrors.NewBadRequestError(fmt.Sprintf("Unknown status operation %s.", operation))
+}
+
+// CheckLoginStatus refuses the sign in of the users that are not active.
+func (user *User) CheckLoginStatus() *resterrors.RestErr {
+	if user.Status == StatusPending {
+		return resterrorsutils.NewForbiddenError("E-mail address not verified.")
+	}
+
+	return user.CheckRestrictedStatus()
+}
+
+// CheckRestrictedStatus refuses the account operations of the suspended and banned users.
+func (user *User) CheckRestrictedStatus() *resterrors.RestErr {
+	switch user.Status {
+	case StatusSuspended:
+		return resterrorsutils.NewForbiddenError("User account suspended.")
+	case StatusBanned:
+		return resterrorsutils.NewForbiddenError("User account banned.")
+	default:
+		return nil
+	}
+}
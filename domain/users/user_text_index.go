@@ -0,0 +1,122 @@
+package users
+
+import (
+	"sort"
+	"strings"
+)
+
+// TextIndex is the in-process full-text index of the users, answering which users hold every term
+// of a query at the start of a word of their names or e-mail.
+type TextIndex interface {
+	// Index adds the user to the index, replacing its previous terms.
+	Index(*User)
+	// Remove drops the user from the index.
+	Remove(int64)
+	// Search returns the score of every user matching all the terms.
+	Search([]string) map[int64]float64
+}
+
+// invertedIndex maps each term to the users holding it and the weight of the best field holding
+// it. The terms are kept sorted to find the ones starting with a query term. It is not safe for
+// concurrent use, the owner must guard it.
+type invertedIndex struct {
+	postings map[string]map[int64]float64
+	terms    []string
+	users    map[int64][]string
+}
+
+// NewInvertedIndex creates the TextIndex keeping an inverted index in memory.
+func NewInvertedIndex() TextIndex {
+	return &invertedIndex{
+		postings: make(map[string]map[int64]float64),
+		users:    make(map[int64][]string),
+	}
+}
+
+// Index adds the terms of the user fields to the index.
+func (i *invertedIndex) Index(user *User) {
+	i.Remove(user.ID)
+
+	weights := make(map[string]float64)
+	for _, field := range textFields {
+		for _, term := range TokenizeText(field.value(user)) {
+			if field.weight > weights[term] {
+				weights[term] = field.weight
+			}
+		}
+	}
+
+	terms := make([]string, 0, len(weights))
+	for term, weight := range weights {
+		if i.postings[term] == nil {
+			i.postings[term] = make(map[int64]float64)
+			i.insertTerm(term)
+		}
+		i.postings[term][user.ID] = weight
+		terms = append(terms, term)
+	}
+	i.users[user.ID] = terms
+}
+
+// Remove drops the user and the terms no other user holds.
+func (i *invertedIndex) Remove(userID int64) {
+	for _, term := range i.users[userID] {
+		delete(i.postings[term], userID)
+		if len(i.postings[term]) == 0 {
+			delete(i.postings, term)
+			i.removeTerm(term)
+		}
+	}
+	delete(i.users, userID)
+}
+
+// Search scores the users holding every query term, either exactly or as the prefix of an indexed
+// term. Exact matches are worth the field weight and prefixes the share of the term they cover.
+func (i *invertedIndex) Search(queryTerms []string) map[int64]float64 {
+	var scores map[int64]float64
+	for _, queryTerm := range queryTerms {
+		termScores := make(map[int64]float64)
+		for index := sort.SearchStrings(i.terms, queryTerm); index < len(i.terms) && strings.HasPrefix(i.terms[index], queryTerm); index++ {
+			term := i.terms[index]
+			coverage := float64(len(queryTerm)) / float64(len(term))
+			for userID, weight := range i.postings[term] {
+				if score := weight * coverage; score > termScores[userID] {
+					termScores[userID] = score
+				}
+			}
+		}
+
+		if scores == nil {
+			scores = termScores
+			continue
+		}
+
+		for userID, score := range scores {
+			if termScore, matched := termScores[userID]; matched {
+				scores[userID] = score + termScore
+			} else {
+				delete(scores, userID)
+			}
+		}
+	}
+
+	if scores == nil {
+		return make(map[int64]float64)
+	}
+
+	return scores
+}
+
+func (i *invertedIndex) insertTerm(term string) {
+	index := sort.SearchStrings(i.terms, term)
+	i.terms = append(i.terms, "")
+	copy(i.terms[index+1:], i.terms[index:])
+	i.terms[index] = term
+}
+
+func (i *invertedIndex) removeTerm(term string) {
+	index := sort.SearchStrings(i.terms, term)
+	if index < len(i.terms) && i.terms[index] == term {
+		i.terms = append(i.terms[:index], i.terms[index+1:]...)
+	}
+}
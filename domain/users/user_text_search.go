@@ -0,0 +1,114 @@
+package users
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+const (
+	highlightStart = "<em>"
+	highlightEnd   = "</em>"
+)
+
+// textFields are the user fields covered by the full-text search, with the weight of their matches.
+var textFields = []struct {
+	name   string
+	weight float64
+	value  func(*User) string
+}{
+	{name: "first_name", weight: 2, value: func(user *User) string { return user.FirstName }},
+	{name: "last_name", weight: 2, value: func(user *User) string { return user.LastName }},
+	{name: "email", weight: 1, value: func(user *User) string { return user.Email }},
+}
+
+// SearchHit is the relevance of an user matching the full-text search and the highlight of the
+// fields that matched.
+type SearchHit struct {
+	Score      float64     `json:"score"`
+	Highlights []Highlight `json:"highlights,omitempty"`
+}
+
+// Highlight is a field matching the full-text search, with the matched parts of the escaped value
+// wrapped in <em> tags.
+type Highlight struct {
+	Field    string `json:"field"`
+	Fragment string `json:"fragment"`
+}
+
+// TokenizeText splits the text into lowercase terms of letters and digits, so "Ann.Smith@x.com"
+// gives "ann", "smith", "x" and "com". Queries and indexes must use the same terms.
+func TokenizeText(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// NewSearchHit builds the hit of the user with the score of the search, highlighting every term
+// of the query at the start of a word of the fields.
+func NewSearchHit(user *User, terms []string, score float64) SearchHit {
+	hit := SearchHit{Score: score}
+	for _, field := range textFields {
+		if fragment, matched := highlight(field.value(user), terms); matched {
+			hit.Highlights = append(hit.Highlights, Highlight{Field: field.name, Fragment: fragment})
+		}
+	}
+
+	return hit
+}
+
+func highlight(value string, terms []string) (string, bool) {
+	// The users are stored in lowercase, so the offsets only differ for the few runes changing
+	// their size, which are then matched as they are.
+	lower := strings.ToLower(value)
+	if len(lower) != len(value) {
+		lower = value
+	}
+	marked := make([]bool, len(value))
+	matched := false
+
+	previous := ' '
+	for index, r := range lower {
+		isWordStart := isTextRune(r) && !isTextRune(previous)
+		previous = r
+		if !isWordStart {
+			continue
+		}
+
+		for _, term := range terms {
+			if strings.HasPrefix(lower[index:], term) {
+				for offset := index; offset < index+len(term); offset++ {
+					marked[offset] = true
+				}
+				matched = true
+			}
+		}
+	}
+
+	if !matched {
+		return "", false
+	}
+
+	var fragment strings.Builder
+	start := 0
+	for index := 0; index <= len(value); index++ {
+		if index < len(value) && (index == 0 || marked[index] == marked[index-1]) {
+			continue
+		}
+
+		if index > start {
+			part := html.EscapeString(value[start:index])
+			if marked[start] {
+				part = highlightStart + part + highlightEnd
+			}
+			fragment.WriteString(part)
+		}
+		start = index
+	}
+
+	return fragment.String(), true
+}
+
+func isTextRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,189 @@
+package users
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTokenizeText(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{text: "Ann.Smith@x.com", want: []string{"ann", "smith", "x", "com"}},
+		{text: "  O'Neil  ", want: []string{"o", "neil"}},
+		{text: "José Ñúñez 2nd", want: []string{"josé", "ñúñez", "2nd"}},
+		{text: "@.-", want: []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			if got := TokenizeText(test.text); fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestInvertedIndexSearch(t *testing.T) {
+	index := NewInvertedIndex()
+	index.Index(&User{ID: 1, FirstName: "ann", LastName: "smith", Email: "ann@example.com"})
+	index.Index(&User{ID: 2, FirstName: "annabel", LastName: "jones", Email: "bel@example.com"})
+	index.Index(&User{ID: 3, FirstName: "joann", LastName: "smithers", Email: "jo@example.com"})
+	index.Index(&User{ID: 4, FirstName: "bob", LastName: "adams", Email: "annex@example.com"})
+
+	tests := []struct {
+		name  string
+		terms []string
+		want  map[int64]float64
+	}{
+		{name: "exact term", terms: []string{"joann"}, want: map[int64]float64{3: 2}},
+		{name: "prefix of the terms", terms: []string{"ann"}, want: map[int64]float64{1: 2, 2: 2 * 3.0 / 7, 4: 1 * 3.0 / 5}},
+		{name: "only at the start of a term", terms: []string{"nn"}, want: map[int64]float64{}},
+		{name: "every term is required", terms: []string{"ann", "smith"}, want: map[int64]float64{1: 4}},
+		// "jo" is both the e-mail term and a name prefix worth less, 2 * 2/5.
+		{name: "best match of each term", terms: []string{"smi", "jo"}, want: map[int64]float64{3: 2*3.0/8 + 1}},
+		{name: "e-mail domain", terms: []string{"example", "bob"}, want: map[int64]float64{4: 3}},
+		{name: "unknown term", terms: []string{"zed"}, want: map[int64]float64{}},
+		{name: "past the last term", terms: []string{"zzz"}, want: map[int64]float64{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := index.Search(test.terms); fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestInvertedIndexUpdate(t *testing.T) {
+	index := NewInvertedIndex()
+	index.Index(&User{ID: 1, FirstName: "ann", LastName: "smith", Email: "ann@example.com"})
+	index.Index(&User{ID: 2, FirstName: "bob", LastName: "smith", Email: "bob@example.com"})
+
+	index.Index(&User{ID: 1, FirstName: "anna", LastName: "jones", Email: "anna@example.com"})
+	if got := index.Search([]string{"smith"}); fmt.Sprint(got) != fmt.Sprint(map[int64]float64{2: 2}) {
+		t.Errorf("searching the previous name: got %v, want only user 2", got)
+	}
+	if got := index.Search([]string{"ann"}); fmt.Sprint(got) != fmt.Sprint(map[int64]float64{1: 2 * 3.0 / 4}) {
+		t.Errorf("searching the new name: got %v, want only user 1", got)
+	}
+
+	index.Remove(1)
+	index.Remove(2)
+	inverted := index.(*invertedIndex)
+	if len(inverted.postings) != 0 || len(inverted.terms) != 0 || len(inverted.users) != 0 {
+		t.Errorf("got %d postings, %d terms and %d users left, want an empty index", len(inverted.postings), len(inverted.terms), len(inverted.users))
+	}
+}
+
+func TestNewSearchHit(t *testing.T) {
+	tests := []struct {
+		name  string
+		user  User
+		terms []string
+		want  []Highlight
+	}{
+		{
+			name:  "whole and prefix terms",
+			user:  User{FirstName: "ann", LastName: "annabel smith", Email: "ann.smith@example.com"},
+			terms: []string{"ann", "smi"},
+			want: []Highlight{
+				{Field: "first_name", Fragment: "<em>ann</em>"},
+				{Field: "last_name", Fragment: "<em>ann</em>abel <em>smi</em>th"},
+				{Field: "email", Fragment: "<em>ann</em>.<em>smi</em>th@example.com"},
+			},
+		},
+		{
+			name:  "only at the start of a word",
+			user:  User{FirstName: "joann", LastName: "ann", Email: "jo@example.com"},
+			terms: []string{"ann"},
+			want:  []Highlight{{Field: "last_name", Fragment: "<em>ann</em>"}},
+		},
+		{
+			name:  "escapes the html",
+			user:  User{FirstName: "<b>ann</b>", LastName: "o'neil & sons", Email: "ann@example.com"},
+			terms: []string{"ann", "neil", "b"},
+			want: []Highlight{
+				{Field: "first_name", Fragment: "&lt;<em>b</em>&gt;<em>ann</em>&lt;/<em>b</em>&gt;"},
+				{Field: "last_name", Fragment: "o&#39;<em>neil</em> &amp; sons"},
+				{Field: "email", Fragment: "<em>ann</em>@example.com"},
+			},
+		},
+		{
+			name:  "overlapping terms",
+			user:  User{FirstName: "annabel", Email: "bel@example.com"},
+			terms: []string{"ann", "anna"},
+			want:  []Highlight{{Field: "first_name", Fragment: "<em>anna</em>bel"}},
+		},
+		{
+			name:  "multibyte runes",
+			user:  User{FirstName: "ñúñez", LastName: "josé", Email: "jose@example.com"},
+			terms: []string{"ñú", "jos"},
+			want: []Highlight{
+				{Field: "first_name", Fragment: "<em>ñú</em>ñez"},
+				{Field: "last_name", Fragment: "<em>jos</em>é"},
+				{Field: "email", Fragment: "<em>jos</em>e@example.com"},
+			},
+		},
+		{
+			name:  "no match",
+			user:  User{FirstName: "bob", Email: "bob@example.com"},
+			terms: []string{"ann"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hit := NewSearchHit(&test.user, test.terms, 1.5)
+			if hit.Score != 1.5 {
+				t.Errorf("got score %v, want 1.5", hit.Score)
+			}
+
+			if fmt.Sprint(hit.Highlights) != fmt.Sprint(test.want) {
+				t.Errorf("got %v, want %v", hit.Highlights, test.want)
+			}
+		})
+	}
+}
+
+func TestSearchRelevanceOffsetPages(t *testing.T) {
+	repository := newTestSearchRepository(t,
+		User{FirstName: "bob", LastName: "annex", Email: "bob@example.com"},
+		User{FirstName: "ann", LastName: "smith", Email: "ann@example.com"},
+		User{FirstName: "cid", LastName: "jones", Email: "annabel@example.com"},
+		User{FirstName: "dan", LastName: "adams", Email: "dan@example.com"},
+		User{FirstName: "annie", LastName: "ann", Email: "annie@example.com"},
+	)
+
+	// The exact name matches score above the name prefixes, and those above the e-mail prefixes.
+	// The best match of the term counts once, so 2 and 5 tie and go by ID.
+	want := []int64{2, 5, 1, 3}
+
+	got := make([]int64, 0)
+	for offset := 0; offset < len(want); offset += 2 {
+		request := SearchRequest{Query: "Ann", Limit: 2, Offset: offset}
+		if err := request.Validate(); err != nil {
+			t.Fatalf("validating offset %d: %s", offset, err.Message)
+		}
+
+		result, err := repository.Search(request)
+		if err != nil {
+			t.Fatalf("searching offset %d: %s", offset, err.Message)
+		}
+		if result.Total != int64(len(want)) || result.NextCursor != "" {
+			t.Errorf("offset %d: got total %d and cursor %q, want %d and no cursor", offset, result.Total, result.NextCursor, len(want))
+		}
+		for _, user := range result.Results {
+			if _, exists := result.Hits[user.ID]; !exists {
+				t.Errorf("offset %d: user %d has no hit", offset, user.ID)
+			}
+		}
+		got = append(got, idsOf(result.Results)...)
+	}
+
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,298 @@
+package users
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/migueloli/bookstore_users-api/utils/dateutils"
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+const (
+	// TransferFormatCSV exports and imports the users as CSV with a header line.
+	TransferFormatCSV = "csv"
+	// TransferFormatNDJSON exports and imports the users as one JSON object per line.
+	TransferFormatNDJSON = "ndjson"
+
+	maxNDJSONLineSize = 64 * 1024
+)
+
+// exportColumns are the user fields written by the export, in the CSV column order. The password
+// hash is never exported.
+var exportColumns = []struct {
+	name  string
+	value func(*User) string
+}{
+	{name: "id", value: func(user *User) string { return fmt.Sprint(user.ID) }},
+	{name: "first_name", value: func(user *User) string { return user.FirstName }},
+	{name: "last_name", value: func(user *User) string { return user.LastName }},
+	{name: "email", value: func(user *User) string { return user.Email }},
+	{name: "date_created", value: func(user *User) string { return user.DateCreated }},
+	{name: "status", value: func(user *User) string { return user.Status }},
+	{name: "status_reason", value: func(user *User) string { return user.StatusReason }},
+	{name: "date_status_changed", value: func(user *User) string { return user.DateStatusChanged }},
+	{name: "date_password_changed", value: func(user *User) string { return user.DatePasswordChanged }},
+}
+
+// importStatuses are the statuses an imported user may keep, pending when not informed.
+var importStatuses = map[string]bool{
+	StatusPending:   true,
+	StatusActive:    true,
+	StatusSuspended: true,
+	StatusBanned:    true,
+}
+
+// ValidateTransferFormat checks that the format is supported by the export and import.
+func ValidateTransferFormat(format string) *resterrors.RestErr {
+	if format != TransferFormatCSV && format != TransferFormatNDJSON {
+		return resterrors.NewBadRequestError(fmt.Sprintf("Invalid format %s, use %s or %s.", format, TransferFormatCSV, TransferFormatNDJSON))
+	}
+
+	return nil
+}
+
+// ExportWriter writes the exported users one at a time.
+type ExportWriter interface {
+	// Write encodes the user, buffering it until the next Flush.
+	Write(*User) error
+	// Flush sends the buffered users to the underlying writer.
+	Flush() error
+}
+
+// NewExportWriter creates the ExportWriter of the format, writing the CSV header right away.
+func NewExportWriter(format string, writer io.Writer) (ExportWriter, error) {
+	if format == TransferFormatNDJSON {
+		buffered := bufio.NewWriter(writer)
+		return &ndjsonExportWriter{writer: buffered, encoder: json.NewEncoder(buffered)}, nil
+	}
+
+	csvWriter := csv.NewWriter(writer)
+	header := make([]string, len(exportColumns))
+	for index, column := range exportColumns {
+		header[index] = column.name
+	}
+	if err := csvWriter.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &csvExportWriter{writer: csvWriter}, nil
+}
+
+type csvExportWriter struct {
+	writer *csv.Writer
+}
+
+func (w *csvExportWriter) Write(user *User) error {
+	record := make([]string, len(exportColumns))
+	for index, column := range exportColumns {
+		record[index] = column.value(user)
+	}
+
+	return w.writer.Write(record)
+}
+
+func (w *csvExportWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+type ndjsonExportWriter struct {
+	writer  *bufio.Writer
+	encoder *json.Encoder
+}
+
+func (w *ndjsonExportWriter) Write(user *User) error {
+	return w.encoder.Encode(user.Marshall(false))
+}
+
+func (w *ndjsonExportWriter) Flush() error {
+	return w.writer.Flush()
+}
+
+// ImportRow is an user read from a line of the import. The Password holds the plain password when
+// the line informs one. Error is set when the line can't be read as an user.
+type ImportRow struct {
+	Line  int
+	User  User
+	Error *resterrors.RestErr
+}
+
+// ImportReader reads the users of the import one line at a time.
+type ImportReader interface {
+	// Read returns the next row or io.EOF after the last one. Any other error stops the import.
+	Read() (*ImportRow, error)
+}
+
+// importRecord is the user of a NDJSON line. Unknown fields, like the ones only written by the
+// export, are ignored.
+type importRecord struct {
+	FirstName   string `json:"first_name"`
+	LastName    string `json:"last_name"`
+	Email       string `json:"email"`
+	Status      string `json:"status"`
+	DateCreated string `json:"date_created"`
+	Password    string `json:"password"`
+}
+
+// NewImportReader creates the ImportReader of the format. The CSV header must have an email
+// column, the other ones are optional and the unknown ones ignored.
+func NewImportReader(format string, reader io.Reader) (ImportReader, *resterrors.RestErr) {
+	if format == TransferFormatNDJSON {
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxNDJSONLineSize)
+		return &ndjsonImportReader{scanner: scanner}, nil
+	}
+
+	csvReader := csv.NewReader(reader)
+	csvReader.ReuseRecord = true
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, resterrors.NewBadRequestError("The CSV should start with a header line.")
+	}
+
+	columns := make(map[string]int, len(header))
+	for index, name := range header {
+		columns[strings.TrimSpace(strings.ToLower(name))] = index
+	}
+	if _, exists := columns["email"]; !exists {
+		return nil, resterrors.NewBadRequestError("The CSV header should have an email column.")
+	}
+
+	return &csvImportReader{reader: csvReader, columns: columns, line: 1}, nil
+}
+
+type csvImportReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+	line    int
+}
+
+// Read returns the next CSV record. The lines are counted by record, so a quoted value spanning
+// many lines counts as one.
+func (r *csvImportReader) Read() (*ImportRow, error) {
+	record, err := r.reader.Read()
+	if err == io.EOF {
+		return nil, err
+	}
+
+	r.line++
+	row := &ImportRow{Line: r.line}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		row.Error = resterrors.NewBadRequestError(fmt.Sprintf("Invalid CSV line: %s.", parseErr.Err))
+		return row, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	row.User = User{
+		FirstName:   r.value(record, "first_name"),
+		LastName:    r.value(record, "last_name"),
+		Email:       r.value(record, "email"),
+		Status:      r.value(record, "status"),
+		DateCreated: r.value(record, "date_created"),
+		Password:    r.value(record, "password"),
+	}
+
+	return row, nil
+}
+
+func (r *csvImportReader) value(record []string, column string) string {
+	if index, exists := r.columns[column]; exists && index < len(record) {
+		return record[index]
+	}
+
+	return ""
+}
+
+type ndjsonImportReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// Read returns the user of the next line that isn't blank.
+func (r *ndjsonImportReader) Read() (*ImportRow, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := strings.TrimSpace(r.scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		row := &ImportRow{Line: r.line}
+		var record importRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			row.Error = resterrors.NewBadRequestError("Invalid JSON line.")
+			return row, nil
+		}
+
+		row.User = User{
+			FirstName:   record.FirstName,
+			LastName:    record.LastName,
+			Email:       record.Email,
+			Status:      record.Status,
+			DateCreated: record.DateCreated,
+			Password:    record.Password,
+		}
+		return row, nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, io.EOF
+}
+
+// ValidateImport checks the user read from an import with Validate, keeping the status and
+// creation date it informs. The password is left to be checked with the Policy.
+func (user *User) ValidateImport() *resterrors.RestErr {
+	if err := user.Validate(); err != nil {
+		return err
+	}
+
+	user.Status = strings.TrimSpace(strings.ToLower(user.Status))
+	if user.Status == "" {
+		user.Status = StatusPending
+	}
+	if !importStatuses[user.Status] {
+		return resterrors.NewBadRequestError(fmt.Sprintf("Invalid status %s.", user.Status))
+	}
+
+	if user.DateCreated = strings.TrimSpace(user.DateCreated); user.DateCreated != "" {
+		if _, err := dateutils.ParseDBString(user.DateCreated); err != nil {
+			return resterrors.NewBadRequestError("Invalid date_created.")
+		}
+	}
+
+	return nil
+}
+
+// ImportLineError is the failure of a line of the import.
+type ImportLineError struct {
+	Line  int                 `json:"line"`
+	Email string              `json:"email,omitempty"`
+	Error *resterrors.RestErr `json:"error"`
+}
+
+// ImportResult is the outcome of the import. On a dry run Imported counts the users that would be
+// imported.
+type ImportResult struct {
+	DryRun   bool              `json:"dry_run"`
+	Lines    int               `json:"lines"`
+	Imported int               `json:"imported"`
+	Failed   int               `json:"failed"`
+	Errors   []ImportLineError `json:"errors"`
+}
+
+// Fail records the error of the line.
+func (r *ImportResult) Fail(line int, email string, err *resterrors.RestErr) {
+	r.Failed++
+	r.Errors = append(r.Errors, ImportLineError{Line: line, Email: email, Error: err})
+}
@@ -1,11 +1,14 @@
 module github.com/migueloli/bookstore_users-api
 
-go 1.15
+go 1.16
 
 require (
-	github.com/gin-gonic/gin v1.6.3
+	github.com/gin-gonic/gin v1.7.7
 	github.com/go-sql-driver/mysql v1.5.0
 	github.com/migueloli/bookstore_oauth-go v1.0.0
 	github.com/migueloli/bookstore_utils-go v1.0.0
+	github.com/prometheus/client_golang v1.12.1
 	go.uber.org/zap v1.16.0
+	golang.org/x/crypto v0.0.0-20211117183948-ae814b36b871
+	gopkg.in/yaml.v2 v2.4.0
 )
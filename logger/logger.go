@@ -1,19 +1,27 @@
 package logger
 
 import (
+	"github.com/migueloli/bookstore_users-api/config"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
 var (
-	log *zap.Logger
+	// log discards the logs until Init is called, so importing the package has no side effects.
+	log = zap.NewNop()
 )
 
-func init() {
+// Init replaces the logger with one following the configuration.
+func Init(cfg config.LoggerConfig) error {
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return err
+	}
+
 	logConfig := zap.Config{
-		OutputPaths: []string{"stdout"},
-		Level:       zap.NewAtomicLevelAt(zap.InfoLevel),
-		Encoding:    "json",
+		OutputPaths: cfg.OutputPaths,
+		Level:       zap.NewAtomicLevelAt(level),
+		Encoding:    cfg.Encoding,
 		EncoderConfig: zapcore.EncoderConfig{
 			LevelKey:     "level",
 			TimeKey:      "time",
@@ -24,11 +32,13 @@ func init() {
 		},
 	}
 
-	var err error
-
-	if log, err = logConfig.Build(); err != nil {
-		panic(err)
+	built, err := logConfig.Build()
+	if err != nil {
+		return err
 	}
+	log = built
+
+	return nil
 }
 
 // Info is an interceptor to log the infos.
@@ -44,3 +54,8 @@ func Error(msg string, err error, tags ...zap.Field) {
 	log.Error(msg, tags...)
 	log.Sync()
 }
+
+// Sync flushes the buffered logs, before the application exits.
+func Sync() {
+	log.Sync()
+}
@@ -1,9 +1,49 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"os"
+
 	"github.com/migueloli/bookstore_users-api/app"
+	"github.com/migueloli/bookstore_users-api/config"
+	"github.com/migueloli/bookstore_users-api/logger"
 )
 
 func main() {
-	app.StartApplication()
+	cfg, args, err := config.Load(os.Args[1:])
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	}
+	if err == config.ErrInvalidFlags {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if err := logger.Init(cfg.Logger); err != nil {
+		fmt.Fprintln(os.Stderr, "error when trying to configure the logger:", err)
+		os.Exit(2)
+	}
+
+	if len(args) > 0 {
+		switch args[0] {
+		case "migrate":
+			os.Exit(app.RunMigrations(cfg, args[1:]))
+		case "export":
+			os.Exit(app.RunExport(cfg, args[1:]))
+		case "import":
+			os.Exit(app.RunImport(cfg, args[1:]))
+		default:
+			fmt.Fprintf(os.Stderr, "unknown command %s, use migrate, export or import.\n", args[0])
+			os.Exit(2)
+		}
+	}
+
+	if err := app.StartApplication(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
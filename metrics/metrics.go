@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "bookstore_users"
+
+	// ResultOK is the result label of the calls without error, the other ones have the status of
+	// the error.
+	ResultOK = "ok"
+
+	// LoginSuccess and LoginFailure are the results of the logins.
+	LoginSuccess = "success"
+	LoginFailure = "failure"
+)
+
+var (
+	registry = prometheus.NewRegistry()
+
+	// HTTPRequests counts the handled requests by method, route and status.
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Handled HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	// HTTPRequestDuration observes the latency of the handled requests by method, route and status.
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of the handled HTTP requests by method, route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// ServiceCalls counts the calls of the services by service, method and result.
+	ServiceCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "service_calls_total",
+		Help:      "Calls of the services by service, method and result.",
+	}, []string{"service", "method", "result"})
+
+	// ServiceCallDuration observes the latency of the calls of the services by service and method.
+	ServiceCallDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "service_call_duration_seconds",
+		Help:      "Latency of the calls of the services by service and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"service", "method"})
+
+	// QueryDuration observes the latency of the queries of the repositories by repository, method
+	// and result. Its count is the number of queries.
+	QueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Latency of the database queries by repository, method and result.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"repository", "method", "result"})
+
+	// Logins counts the logins by result.
+	Logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Logins by result.",
+	}, []string{"result"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPRequestDuration,
+		ServiceCalls,
+		ServiceCallDuration,
+		QueryDuration,
+		Logins,
+	)
+}
+
+// RegisterDB exposes the connection pool statistics of the database under the name.
+func RegisterDB(db *sql.DB, name string) {
+	registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// Result is the result label of a call returning the error.
+func Result(err *resterrors.RestErr) string {
+	if err == nil {
+		return ResultOK
+	}
+
+	return strconv.Itoa(err.Status)
+}
@@ -0,0 +1,36 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/migueloli/bookstore_oauth-go/oauth"
+	"github.com/migueloli/bookstore_users-api/utils/resterrorsutils"
+)
+
+const (
+	callerIDKey = "caller_id"
+)
+
+// Authenticate is the middleware refusing the requests without a valid access token.
+func Authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := oauth.AuthenticateRequest(c.Request); err != nil {
+			c.AbortWithStatusJSON(err.Status, err)
+			return
+		}
+
+		callerID := oauth.GetCallerID(c.Request)
+		if callerID == 0 {
+			restErr := resterrorsutils.NewUnauthorizedError("Authentication required.")
+			c.AbortWithStatusJSON(restErr.Status, restErr)
+			return
+		}
+
+		c.Set(callerIDKey, callerID)
+		c.Next()
+	}
+}
+
+// GetCallerID returns the ID of the user authenticated by the Authenticate middleware, or 0.
+func GetCallerID(c *gin.Context) int64 {
+	return c.GetInt64(callerIDKey)
+}
@@ -0,0 +1,73 @@
+package middlewares
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/migueloli/bookstore_users-api/services"
+	"github.com/migueloli/bookstore_users-api/utils/resterrorsutils"
+)
+
+// RequireOwner is the middleware allowing only the user identified by the path param.
+// It must run after Authenticate.
+func RequireOwner(userIDParam string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isOwner(c, userIDParam) {
+			restErr := resterrorsutils.NewForbiddenError("Only the owner can perform this operation.")
+			c.AbortWithStatusJSON(restErr.Status, restErr)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireOwnerOrPermission is the middleware allowing only the user identified by the path param
+// or the holders of the permission. It must run after Authenticate.
+func RequireOwnerOrPermission(userIDParam string, permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isOwner(c, userIDParam) {
+			c.Next()
+			return
+		}
+
+		requirePermission(c, permission)
+	}
+}
+
+// RequirePermission is the middleware allowing only the holders of the permission.
+// It must run after Authenticate.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requirePermission(c, permission)
+	}
+}
+
+// HasPermission checks if the caller authenticated by the Authenticate middleware holds the
+// permission. Failures to load the roles count as not holding it.
+func HasPermission(c *gin.Context, permission string) bool {
+	allowed, err := services.AuthorizationService.HasPermission(GetCallerID(c), permission)
+	return err == nil && allowed
+}
+
+func requirePermission(c *gin.Context, permission string) {
+	allowed, err := services.AuthorizationService.HasPermission(GetCallerID(c), permission)
+	if err != nil {
+		c.AbortWithStatusJSON(err.Status, err)
+		return
+	}
+
+	if !allowed {
+		restErr := resterrorsutils.NewForbiddenError("Permission " + permission + " required.")
+		c.AbortWithStatusJSON(restErr.Status, restErr)
+		return
+	}
+
+	c.Next()
+}
+
+func isOwner(c *gin.Context, userIDParam string) bool {
+	callerID := GetCallerID(c)
+	userID, err := strconv.ParseInt(c.Param(userIDParam), 10, 64)
+	return err == nil && callerID != 0 && callerID == userID
+}
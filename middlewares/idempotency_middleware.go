@@ -0,0 +1,128 @@
+package middlewares
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/migueloli/bookstore_oauth-go/oauth"
+	"github.com/migueloli/bookstore_users-api/domain/idempotency"
+	"github.com/migueloli/bookstore_users-api/services"
+	"github.com/migueloli/bookstore_users-api/utils/cryptoutils"
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+const (
+	idempotencyKeyHeader      = "Idempotency-Key"
+	idempotentReplayedHeader  = "Idempotent-Replayed"
+	idempotencyContentTypeKey = "Content-Type"
+)
+
+// replayedHeaders are the response headers stored with the body to be replayed.
+var replayedHeaders = []string{idempotencyContentTypeKey, "ETag", "Location"}
+
+// responseRecorder keeps a copy of the body written to the client.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(data string) (int, error) {
+	w.body.WriteString(data)
+	return w.ResponseWriter.WriteString(data)
+}
+
+// Idempotency is the middleware replaying the first response of a request with an Idempotency-Key
+// to its retries by the same caller, or by the same client or IP for the anonymous ones. Reusing
+// the key for another request is refused with 422. Server errors and rate limits aren't stored, so
+// they can be retried. It must run after the authorization middlewares, so refused requests don't
+// take the key.
+func Idempotency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		body, err := c.GetRawData()
+		if err != nil {
+			restErr := resterrors.NewBadRequestError("Invalid request body.")
+			c.AbortWithStatusJSON(restErr.Status, restErr)
+			return
+		}
+		c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		fingerprint := cryptoutils.HashToken(c.Request.Method + " " + c.Request.URL.Path + "\n" + string(body))
+		record, replay, restErr := services.IdempotencyService.Begin(key, idempotencyScope(c), fingerprint)
+		if restErr != nil {
+			c.AbortWithStatusJSON(restErr.Status, restErr)
+			return
+		}
+
+		if replay {
+			replayRecord(c, record)
+			return
+		}
+
+		completed := false
+		defer func() {
+			if !completed {
+				services.IdempotencyService.Release(record)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError || status == http.StatusTooManyRequests {
+			return
+		}
+
+		record.Status = status
+		record.Body = recorder.body.Bytes()
+		record.Headers = make(map[string]string)
+		for _, name := range replayedHeaders {
+			if value := recorder.Header().Get(name); value != "" {
+				record.Headers[name] = value
+			}
+		}
+
+		services.IdempotencyService.Complete(record)
+		completed = true
+	}
+}
+
+// idempotencyScope returns who owns the Idempotency-Key, so no one can replay the responses of
+// someone else. The anonymous callers are told apart by their client, or their IP without one.
+func idempotencyScope(c *gin.Context) string {
+	if callerID := oauth.GetCallerID(c.Request); callerID > 0 {
+		return strconv.FormatInt(callerID, 10)
+	}
+
+	if clientID := oauth.GetClientID(c.Request); clientID > 0 {
+		return "client-" + strconv.FormatInt(clientID, 10)
+	}
+
+	return "ip-" + c.ClientIP()
+}
+
+func replayRecord(c *gin.Context, record *idempotency.Record) {
+	for name, value := range record.Headers {
+		if name != idempotencyContentTypeKey {
+			c.Header(name, value)
+		}
+	}
+	c.Header(idempotentReplayedHeader, "true")
+	c.Data(record.Status, record.Headers[idempotencyContentTypeKey], record.Body)
+	c.Abort()
+}
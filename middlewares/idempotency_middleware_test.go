@@ -0,0 +1,150 @@
+package middlewares
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/migueloli/bookstore_users-api/domain/idempotency"
+	"github.com/migueloli/bookstore_users-api/services"
+)
+
+// newIdempotentRouter serves POST /users behind the Idempotency middleware, answering with the
+// status the handler returns and counting the requests that reached it.
+func newIdempotentRouter(handler func(c *gin.Context) int) (*gin.Engine, *int) {
+	gin.SetMode(gin.TestMode)
+	services.IdempotencyService = services.NewIdempotencyService(idempotency.NewMemoryRepository(), time.Hour)
+
+	calls := 0
+	router := gin.New()
+	router.POST("/users", Idempotency(), func(c *gin.Context) {
+		calls++
+		status := handler(c)
+		c.Header("Location", fmt.Sprintf("/users/%d", calls))
+		c.JSON(status, gin.H{"call": calls})
+	})
+
+	return router, &calls
+}
+
+func postIdempotent(router http.Handler, key string, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Caller-Id", "1")
+	if key != "" {
+		request.Header.Set(idempotencyKeyHeader, key)
+	}
+
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	return response
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	router, calls := newIdempotentRouter(func(*gin.Context) int { return http.StatusCreated })
+
+	first := postIdempotent(router, "key", `{"email": "user@example.com"}`)
+	retry := postIdempotent(router, "key", `{"email": "user@example.com"}`)
+
+	if *calls != 1 {
+		t.Errorf("got %d calls to the handler, want 1", *calls)
+	}
+	if retry.Code != first.Code || retry.Body.String() != first.Body.String() {
+		t.Errorf("got retry %d %s, want %d %s", retry.Code, retry.Body, first.Code, first.Body)
+	}
+	if got := retry.Header().Get("Location"); got != "/users/1" {
+		t.Errorf("got Location %q replayed, want /users/1", got)
+	}
+	if got := retry.Header().Get("Content-Type"); got != first.Header().Get("Content-Type") {
+		t.Errorf("got Content-Type %q replayed, want %q", got, first.Header().Get("Content-Type"))
+	}
+	if first.Header().Get(idempotentReplayedHeader) != "" || retry.Header().Get(idempotentReplayedHeader) != "true" {
+		t.Errorf("got %s %q and %q, want only the retry marked", idempotentReplayedHeader, first.Header().Get(idempotentReplayedHeader), retry.Header().Get(idempotentReplayedHeader))
+	}
+
+	postIdempotent(router, "", `{"email": "user@example.com"}`)
+	postIdempotent(router, "other key", `{"email": "user@example.com"}`)
+	if *calls != 3 {
+		t.Errorf("got %d calls to the handler, want 3 with no key and another key", *calls)
+	}
+}
+
+func TestIdempotencyFingerprintMismatch(t *testing.T) {
+	router, calls := newIdempotentRouter(func(*gin.Context) int { return http.StatusCreated })
+
+	postIdempotent(router, "key", `{"email": "user@example.com"}`)
+	reused := postIdempotent(router, "key", `{"email": "other@example.com"}`)
+
+	if reused.Code != http.StatusUnprocessableEntity {
+		t.Errorf("got status %d, want %d", reused.Code, http.StatusUnprocessableEntity)
+	}
+	if *calls != 1 {
+		t.Errorf("got %d calls to the handler, want 1", *calls)
+	}
+}
+
+func TestIdempotencyInFlight(t *testing.T) {
+	started, finish := make(chan struct{}), make(chan struct{})
+	router, calls := newIdempotentRouter(func(*gin.Context) int {
+		close(started)
+		<-finish
+		return http.StatusCreated
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- postIdempotent(router, "key", `{}`) }()
+	<-started
+
+	if concurrent := postIdempotent(router, "key", `{}`); concurrent.Code != http.StatusConflict {
+		t.Errorf("got status %d while in flight, want %d", concurrent.Code, http.StatusConflict)
+	}
+
+	close(finish)
+	if first := <-done; first.Code != http.StatusCreated {
+		t.Errorf("got status %d for the first request, want %d", first.Code, http.StatusCreated)
+	}
+	if retry := postIdempotent(router, "key", `{}`); retry.Code != http.StatusCreated || retry.Header().Get(idempotentReplayedHeader) != "true" {
+		t.Errorf("got status %d after completion, want the %d replayed", retry.Code, http.StatusCreated)
+	}
+	if *calls != 1 {
+		t.Errorf("got %d calls to the handler, want 1", *calls)
+	}
+}
+
+func TestIdempotencyReleasedAfterError(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+	}{
+		{name: "server error", status: http.StatusInternalServerError},
+		{name: "unavailable", status: http.StatusServiceUnavailable},
+		{name: "rate limited", status: http.StatusTooManyRequests},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			statuses := []int{test.status, http.StatusCreated}
+			router, calls := newIdempotentRouter(func(*gin.Context) int {
+				status := statuses[0]
+				statuses = statuses[1:]
+				return status
+			})
+
+			if failed := postIdempotent(router, "key", `{}`); failed.Code != test.status {
+				t.Fatalf("got status %d, want %d", failed.Code, test.status)
+			}
+
+			retry := postIdempotent(router, "key", `{}`)
+			if retry.Code != http.StatusCreated || retry.Header().Get(idempotentReplayedHeader) != "" {
+				t.Errorf("got status %d replayed %q, want a new %d", retry.Code, retry.Header().Get(idempotentReplayedHeader), http.StatusCreated)
+			}
+			if *calls != 2 {
+				t.Errorf("got %d calls to the handler, want 2", *calls)
+			}
+		})
+	}
+}
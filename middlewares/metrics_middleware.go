@@ -0,0 +1,30 @@
+package middlewares
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/migueloli/bookstore_users-api/metrics"
+)
+
+// unmatchedRoute is the route label of the requests not matching any route, so the paths of the
+// clients don't create labels.
+const unmatchedRoute = "unmatched"
+
+// Metrics is the middleware counting and timing every request by method, route and status.
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		status := strconv.Itoa(c.Writer.Status())
+
+		metrics.HTTPRequests.WithLabelValues(c.Request.Method, route, status).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
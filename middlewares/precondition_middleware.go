@@ -0,0 +1,20 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/migueloli/bookstore_users-api/utils/resterrorsutils"
+)
+
+// RequireIfMatch is the middleware refusing the writes without an If-Match header when required,
+// so clients can't skip the optimistic concurrency check.
+func RequireIfMatch(required bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if required && c.GetHeader("If-Match") == "" {
+			restErr := resterrorsutils.NewPreconditionRequiredError("The If-Match header is required.")
+			c.AbortWithStatusJSON(restErr.Status, restErr)
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,22 @@
+package notifications
+
+import (
+	"github.com/migueloli/bookstore_users-api/logger"
+	"go.uber.org/zap"
+)
+
+type logNotifier struct{}
+
+// NewLogNotifier creates a Notifier writing the messages to the application log, for local use only.
+func NewLogNotifier() Notifier {
+	return &logNotifier{}
+}
+
+func (n *logNotifier) Notify(message Message) error {
+	logger.Info("Notification sent.",
+		zap.String("to", message.To),
+		zap.String("subject", message.Subject),
+		zap.String("body", message.Body),
+	)
+	return nil
+}
@@ -0,0 +1,13 @@
+package notifications
+
+// Message is the content delivered to a user.
+type Message struct {
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// Notifier delivers messages to the users.
+type Notifier interface {
+	Notify(Message) error
+}
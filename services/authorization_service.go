@@ -0,0 +1,116 @@
+package services
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/migueloli/bookstore_users-api/domain/users"
+	"github.com/migueloli/bookstore_users-api/utils/dateutils"
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+var (
+	// AuthorizationService is the access point to the authorizationServiceInterface, configured by
+	// the application with the repositories in use.
+	AuthorizationService authorizationServiceInterface
+)
+
+type authorizationService struct {
+	users users.UserRepository
+	roles users.RoleRepository
+}
+
+type authorizationServiceInterface interface {
+	GetRoles(int64) (*users.UserRoles, *resterrors.RestErr)
+	GrantRole(int64, string, users.AuditActor) (*users.UserRoles, *resterrors.RestErr)
+	RevokeRole(int64, string, users.AuditActor) (*users.UserRoles, *resterrors.RestErr)
+	HasPermission(int64, string) (bool, *resterrors.RestErr)
+}
+
+// NewAuthorizationService creates the authorizationServiceInterface checking the users with the
+// repository and keeping their roles with the role repository.
+func NewAuthorizationService(usersRepository users.UserRepository, roles users.RoleRepository) authorizationServiceInterface {
+	return &authorizationService{
+		users: usersRepository,
+		roles: roles,
+	}
+}
+
+// GetRoles is a service to list the roles held by the user.
+func (s *authorizationService) GetRoles(userID int64) (*users.UserRoles, *resterrors.RestErr) {
+	if err := s.users.Get(&users.User{ID: userID}); err != nil {
+		return nil, err
+	}
+
+	return s.getRoles(userID)
+}
+
+// GrantRole is a service to grant the role to the user.
+func (s *authorizationService) GrantRole(userID int64, role string, actor users.AuditActor) (*users.UserRoles, *resterrors.RestErr) {
+	role = strings.TrimSpace(strings.ToLower(role))
+	if err := users.ValidateRole(role); err != nil {
+		return nil, err
+	}
+
+	if err := s.users.Get(&users.User{ID: userID}); err != nil {
+		return nil, err
+	}
+
+	entry := users.NewRoleAuditEntry(actor, users.AuditActionRoleGrant, userID, role)
+	if err := s.roles.Grant(userID, role, dateutils.GetNowDBString(), entry); err != nil {
+		return nil, err
+	}
+
+	return s.getRoles(userID)
+}
+
+// RevokeRole is a service to revoke the role from the user.
+func (s *authorizationService) RevokeRole(userID int64, role string, actor users.AuditActor) (*users.UserRoles, *resterrors.RestErr) {
+	role = strings.TrimSpace(strings.ToLower(role))
+	if err := users.ValidateRole(role); err != nil {
+		return nil, err
+	}
+
+	entry := users.NewRoleAuditEntry(actor, users.AuditActionRoleRevoke, userID, role)
+	if err := s.roles.Revoke(userID, role, entry); err != nil {
+		return nil, err
+	}
+
+	return s.getRoles(userID)
+}
+
+// HasPermission is a service to check if any role of the user holds the permission. Only active
+// users hold permissions, so a suspended, banned or deleted admin loses them right away.
+func (s *authorizationService) HasPermission(userID int64, permission string) (bool, *resterrors.RestErr) {
+	if userID == 0 {
+		return false, nil
+	}
+
+	caller := &users.User{ID: userID}
+	if err := s.users.Get(caller); err != nil {
+		if err.Status == http.StatusNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	if caller.Status != users.StatusActive {
+		return false, nil
+	}
+
+	roles, err := s.roles.GetRoles(userID)
+	if err != nil {
+		return false, err
+	}
+
+	return users.HasPermission(roles, permission), nil
+}
+
+func (s *authorizationService) getRoles(userID int64) (*users.UserRoles, *resterrors.RestErr) {
+	granted, err := s.roles.GetRoles(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := users.NewUserRoles(userID, granted)
+	return &roles, nil
+}
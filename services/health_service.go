@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/migueloli/bookstore_users-api/domain/health"
+)
+
+var (
+	// HealthService is the access point to the healthServiceInterface, configured by the
+	// application with the checks of its dependencies.
+	HealthService healthServiceInterface
+)
+
+type healthService struct {
+	registry     *health.Registry
+	shuttingDown int32
+}
+
+type healthServiceInterface interface {
+	Live() *health.Report
+	Ready(context.Context) *health.Report
+	ShutDown()
+}
+
+// NewHealthService creates the healthServiceInterface running the checks of the registry.
+func NewHealthService(registry *health.Registry) healthServiceInterface {
+	return &healthService{registry: registry}
+}
+
+// Live is a service to tell the process is running, without checking any dependency.
+func (s *healthService) Live() *health.Report {
+	return &health.Report{Status: health.StatusUp, Checks: make([]health.CheckResult, 0)}
+}
+
+// Ready is a service to tell whether the application can serve requests, checking every
+// registered dependency. Once shutting down it fails without running the checks.
+func (s *healthService) Ready(ctx context.Context) *health.Report {
+	if atomic.LoadInt32(&s.shuttingDown) == 1 {
+		return &health.Report{Status: health.StatusShuttingDown, Checks: make([]health.CheckResult, 0)}
+	}
+
+	return s.registry.Run(ctx)
+}
+
+// ShutDown is a service to fail the readiness from now on, so no new requests are routed to the
+// application while it drains the in-flight ones.
+func (s *healthService) ShutDown() {
+	atomic.StoreInt32(&s.shuttingDown, 1)
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/migueloli/bookstore_users-api/domain/idempotency"
+	"github.com/migueloli/bookstore_users-api/logger"
+	"github.com/migueloli/bookstore_users-api/utils/cryptoutils"
+	"github.com/migueloli/bookstore_users-api/utils/dateutils"
+	"github.com/migueloli/bookstore_users-api/utils/resterrorsutils"
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+const (
+	maxIdempotencyKeyLength = 255
+)
+
+var (
+	// IdempotencyService is the access point to the idempotencyServiceInterface, configured by the
+	// application with the repository and retention in use.
+	IdempotencyService idempotencyServiceInterface
+)
+
+type idempotencyService struct {
+	repository idempotency.Repository
+	ttl        time.Duration
+}
+
+type idempotencyServiceInterface interface {
+	Begin(string, string, string) (*idempotency.Record, bool, *resterrors.RestErr)
+	Complete(*idempotency.Record)
+	Release(*idempotency.Record)
+	PurgeExpired() (int64, *resterrors.RestErr)
+}
+
+// NewIdempotencyService creates the idempotencyServiceInterface keeping the responses with the
+// repository for the ttl.
+func NewIdempotencyService(repository idempotency.Repository, ttl time.Duration) idempotencyServiceInterface {
+	return &idempotencyService{
+		repository: repository,
+		ttl:        ttl,
+	}
+}
+
+// Begin is a service to reserve the Idempotency-Key within the scope of the caller for the request
+// fingerprint. It returns the new record to complete, or the stored one to replay when the key was
+// already used for the same request.
+func (s *idempotencyService) Begin(key string, scope string, fingerprint string) (*idempotency.Record, bool, *resterrors.RestErr) {
+	if len(key) > maxIdempotencyKeyLength {
+		return nil, false, resterrors.NewBadRequestError(fmt.Sprintf("Idempotency-Key should have at most %d characters.", maxIdempotencyKeyLength))
+	}
+
+	now := dateutils.GetNow()
+	record := &idempotency.Record{
+		KeyHash:     cryptoutils.HashToken(scope + ":" + key),
+		Fingerprint: fingerprint,
+		DateCreated: dateutils.GetDBString(now),
+		DateExpires: dateutils.GetDBString(now.Add(s.ttl)),
+	}
+
+	current, err := s.repository.Reserve(record, record.DateCreated)
+	if err != nil {
+		return nil, false, err
+	}
+	if current == nil {
+		return record, false, nil
+	}
+
+	if current.Fingerprint != fingerprint {
+		return nil, false, resterrorsutils.NewUnprocessableEntityError("Idempotency-Key already used with a different request.")
+	}
+
+	if !current.IsCompleted() {
+		return nil, false, resterrorsutils.NewConflictError("A request with this Idempotency-Key is still in progress.")
+	}
+
+	return current, true, nil
+}
+
+// Complete is a service to store the response of the reserved record. A failure only costs the
+// replay, so it is logged and the response is still given.
+func (s *idempotencyService) Complete(record *idempotency.Record) {
+	if err := s.repository.Complete(record); err != nil {
+		logger.Error("Error when trying to complete the idempotency record.", errors.New(err.Message))
+	}
+}
+
+// Release is a service to drop the reserved record whose response must not be replayed.
+func (s *idempotencyService) Release(record *idempotency.Record) {
+	if err := s.repository.Release(record.KeyHash); err != nil {
+		logger.Error("Error when trying to release the idempotency record.", errors.New(err.Message))
+	}
+}
+
+// PurgeExpired is a service to remove the records that can't be replayed anymore.
+func (s *idempotencyService) PurgeExpired() (int64, *resterrors.RestErr) {
+	return s.repository.Purge(dateutils.GetNowDBString())
+}
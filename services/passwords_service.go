@@ -0,0 +1,187 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/migueloli/bookstore_users-api/domain/users"
+	"github.com/migueloli/bookstore_users-api/logger"
+	"github.com/migueloli/bookstore_users-api/notifications"
+	"github.com/migueloli/bookstore_users-api/utils/cryptoutils"
+	"github.com/migueloli/bookstore_users-api/utils/dateutils"
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+const (
+	passwordResetTokenTTL = 30 * time.Minute
+)
+
+var (
+	// PasswordsService is the access point to the passwordsServiceInterface, configured by the
+	// application with the repositories and notifier in use.
+	PasswordsService passwordsServiceInterface
+)
+
+type passwordsService struct {
+	users    users.UserRepository
+	resets   users.PasswordResetRepository
+	notifier notifications.Notifier
+}
+
+type passwordsServiceInterface interface {
+	ForgotPassword(users.ForgotPasswordRequest) *resterrors.RestErr
+	ResetPassword(users.ResetPasswordRequest, users.AuditActor) *resterrors.RestErr
+	ChangePassword(int64, users.ChangePasswordRequest, users.AuditActor) *resterrors.RestErr
+}
+
+// NewPasswordsService creates the passwordsServiceInterface handling the password recovery.
+func NewPasswordsService(usersRepository users.UserRepository, resetsRepository users.PasswordResetRepository, notifier notifications.Notifier) passwordsServiceInterface {
+	return &passwordsService{
+		users:    usersRepository,
+		resets:   resetsRepository,
+		notifier: notifier,
+	}
+}
+
+// ForgotPassword is a service to issue a password reset token and send it to the user. It
+// doesn't tell if the e-mail is registered, so only unexpected errors are returned.
+func (s *passwordsService) ForgotPassword(request users.ForgotPasswordRequest) *resterrors.RestErr {
+	user := &users.User{Email: strings.TrimSpace(strings.ToLower(request.Email))}
+	if user.Email == "" {
+		return resterrors.NewBadRequestError("Invalid e-mail address.")
+	}
+
+	if err := s.users.FindByEmail(user); err != nil {
+		if err.Status == http.StatusNotFound {
+			return nil
+		}
+		return err
+	}
+
+	if user.Status != users.StatusActive {
+		return nil
+	}
+
+	token, tokenErr := cryptoutils.GenerateToken()
+	if tokenErr != nil {
+		logger.Error("Error when trying to generate the password reset token.", tokenErr)
+		return resterrors.NewInternalServerError("Error when trying to generate the password reset token.", errors.New("crypto error"))
+	}
+
+	now := dateutils.GetNow()
+	reset := &users.PasswordResetToken{
+		UserID:      user.ID,
+		TokenHash:   cryptoutils.HashToken(token),
+		DateCreated: dateutils.GetDBString(now),
+		DateExpires: dateutils.GetDBString(now.Add(passwordResetTokenTTL)),
+	}
+	if err := s.resets.Save(reset); err != nil {
+		return err
+	}
+
+	message := notifications.Message{
+		To:      user.Email,
+		Subject: "Password reset",
+		Body:    fmt.Sprintf("Use the token %s to reset your password. It expires at %s UTC.", token, reset.DateExpires),
+	}
+	if err := s.notifier.Notify(message); err != nil {
+		logger.Error("Error when trying to send the password reset token.", err)
+		return resterrors.NewInternalServerError("Error when trying to send the password reset token.", errors.New("notification error"))
+	}
+
+	return nil
+}
+
+// ResetPassword is a service to define a new password consuming a reset token. Every other
+// outstanding token of the user is invalidated.
+func (s *passwordsService) ResetPassword(request users.ResetPasswordRequest, actor users.AuditActor) *resterrors.RestErr {
+	if err := request.Validate(); err != nil {
+		return err
+	}
+
+	// The token is only consumed once the password is accepted, so a refused one can be retried.
+	now := dateutils.GetNowDBString()
+	reset := &users.PasswordResetToken{TokenHash: cryptoutils.HashToken(request.Token)}
+	if err := s.resets.Find(reset, now); err != nil {
+		return err
+	}
+
+	user := &users.User{ID: reset.UserID}
+	if err := s.users.Get(user); err != nil {
+		return err
+	}
+
+	if err := user.CheckRestrictedStatus(); err != nil {
+		return err
+	}
+
+	if err := users.Policy.Validate("password", request.Password, user); err != nil {
+		return err
+	}
+
+	if err := s.resets.Consume(reset, now); err != nil {
+		return err
+	}
+
+	// The token proves who the caller is.
+	actor.UserID = user.ID
+	return s.storePassword(user, request.Password, now, users.NewAuditEntry(actor, users.AuditActionPasswordReset, user.ID))
+}
+
+// ChangePassword is a service to replace the password of the user after verifying the current one.
+func (s *passwordsService) ChangePassword(userID int64, request users.ChangePasswordRequest, actor users.AuditActor) *resterrors.RestErr {
+	if err := request.Validate(); err != nil {
+		return err
+	}
+
+	// Get doesn't load the password hash, the user is reloaded by e-mail to verify it.
+	user := &users.User{ID: userID}
+	if err := s.users.Get(user); err != nil {
+		return err
+	}
+	if err := user.CheckRestrictedStatus(); err != nil {
+		return err
+	}
+	if err := s.users.FindByEmail(user); err != nil {
+		return err
+	}
+
+	ok, _, verifyErr := cryptoutils.Passwords.Verify(request.CurrentPassword, user.Password)
+	if verifyErr != nil {
+		logger.Error("Error when trying to verify the user password.", verifyErr)
+	}
+	if !ok {
+		return resterrors.NewBadRequestError("Invalid current password.")
+	}
+
+	if err := users.Policy.Validate("new_password", request.NewPassword, user); err != nil {
+		return err
+	}
+
+	return s.storePassword(user, request.NewPassword, dateutils.GetNowDBString(), users.NewAuditEntry(actor, users.AuditActionPasswordChange, user.ID))
+}
+
+// storePassword hashes and stores the new password recording when it changed, and invalidates the
+// outstanding reset tokens. The entry records the change. The date is returned as
+// date_password_changed by the private user payload, the login response included, so the OAuth API
+// can reject the access tokens issued before it; revoking them is out of scope for this API.
+func (s *passwordsService) storePassword(user *users.User, password string, now string, entry *users.AuditEntry) *resterrors.RestErr {
+	hash, hashErr := cryptoutils.Passwords.Hash(password)
+	if hashErr != nil {
+		logger.Error("Error when trying to hash the user password.", hashErr)
+		return resterrors.NewInternalServerError("Error when trying to hash the user password.", errors.New("crypto error"))
+	}
+
+	before := *user
+	user.Password = hash
+	user.DatePasswordChanged = now
+	entry.Diff(&before, user)
+	if err := s.users.UpdatePassword(user, entry); err != nil {
+		return err
+	}
+
+	return s.resets.InvalidateAll(user.ID, now)
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/migueloli/bookstore_users-api/domain/users"
+	"github.com/migueloli/bookstore_users-api/logger"
+	"github.com/migueloli/bookstore_users-api/utils/cryptoutils"
+	"github.com/migueloli/bookstore_users-api/utils/dateutils"
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+var (
+	// TransferService is the access point to the transferServiceInterface, configured by the
+	// application with the repository in use.
+	TransferService transferServiceInterface
+)
+
+type transferService struct {
+	repository users.UserRepository
+}
+
+type transferServiceInterface interface {
+	ExportUsers(users.SearchRequest, string, io.Writer) *resterrors.RestErr
+	ImportUsers(io.Reader, string, bool, users.AuditActor) (*users.ImportResult, *resterrors.RestErr)
+}
+
+// NewTransferService creates the transferServiceInterface exporting and importing the users of the
+// repository.
+func NewTransferService(repository users.UserRepository) transferServiceInterface {
+	return &transferService{repository: repository}
+}
+
+// ExportUsers is a service to write every user matching the filters of the search in the format,
+// sorted by ID. Nothing is written when the format or the filters are invalid.
+func (s *transferService) ExportUsers(request users.SearchRequest, format string, writer io.Writer) *resterrors.RestErr {
+	if err := users.ValidateTransferFormat(format); err != nil {
+		return err
+	}
+
+	if err := request.Validate(); err != nil {
+		return err
+	}
+
+	exportWriter, err := users.NewExportWriter(format, writer)
+	if err != nil {
+		logger.Error("Error when trying to start the users export.", err)
+		return resterrors.NewInternalServerError("Error when trying to start the users export.", errors.New("export error"))
+	}
+
+	if err := s.repository.Stream(request, exportWriter.Write); err != nil {
+		return err
+	}
+
+	if err := exportWriter.Flush(); err != nil {
+		logger.Error("Error when trying to finish the users export.", err)
+		return resterrors.NewInternalServerError("Error when trying to finish the users export.", errors.New("export error"))
+	}
+
+	return nil
+}
+
+// ImportUsers is a service to create the users read from the format, reporting the lines that
+// fail. The users are saved in batches of users.MaxBatchSize as they are read, without sending
+// e-mail verifications. A dry run only validates the lines and the existing e-mails.
+func (s *transferService) ImportUsers(reader io.Reader, format string, dryRun bool, actor users.AuditActor) (*users.ImportResult, *resterrors.RestErr) {
+	if err := users.ValidateTransferFormat(format); err != nil {
+		return nil, err
+	}
+
+	importReader, err := users.NewImportReader(format, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &users.ImportResult{DryRun: dryRun, Errors: make([]users.ImportLineError, 0)}
+	emails := make(map[string]int)
+	batch := make([]*users.ImportRow, 0, users.MaxBatchSize)
+	for {
+		row, readErr := importReader.Read()
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			logger.Error("Error when trying to read the users import.", readErr)
+			return nil, resterrors.NewBadRequestError(fmt.Sprintf("Error when trying to read the import after %d lines.", result.Lines))
+		}
+
+		result.Lines++
+		if row.Error == nil {
+			row.Error = prepareImportedUser(&row.User)
+		}
+		if line, repeated := emails[row.User.Email]; row.Error == nil && repeated {
+			row.Error = resterrors.NewBadRequestError(fmt.Sprintf("E-mail %s already imported at line %d.", row.User.Email, line))
+		}
+		if row.Error != nil {
+			result.Fail(row.Line, row.User.Email, row.Error)
+			continue
+		}
+
+		emails[row.User.Email] = row.Line
+		if batch = append(batch, row); len(batch) == users.MaxBatchSize {
+			if err := s.importBatch(batch, result, actor); err != nil {
+				return nil, err
+			}
+			batch = batch[:0]
+		}
+	}
+
+	if err := s.importBatch(batch, result, actor); err != nil {
+		return nil, err
+	}
+
+	// The e-mails already existing are only found when their batch is saved.
+	sort.SliceStable(result.Errors, func(i, j int) bool { return result.Errors[i].Line < result.Errors[j].Line })
+
+	return result, nil
+}
+
+// importBatch saves the valid rows together, or only checks their e-mails on a dry run.
+func (s *transferService) importBatch(batch []*users.ImportRow, result *users.ImportResult, actor users.AuditActor) *resterrors.RestErr {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	if result.DryRun {
+		for _, row := range batch {
+			err := s.repository.FindByEmail(&users.User{Email: row.User.Email})
+			if err == nil {
+				result.Fail(row.Line, row.User.Email, resterrors.NewBadRequestError(fmt.Sprintf("E-mail %s already exists.", row.User.Email)))
+				continue
+			}
+			if err.Status != http.StatusNotFound {
+				return err
+			}
+			result.Imported++
+		}
+		return nil
+	}
+
+	valid := make(users.Users, len(batch))
+	entries := make([]*users.AuditEntry, len(batch))
+	for index, row := range batch {
+		valid[index] = row.User
+		entries[index] = users.NewAuditEntry(actor, users.AuditActionImport, 0)
+		entries[index].Diff(nil, &row.User)
+	}
+
+	errs, err := s.repository.SaveAll(valid, entries)
+	if err != nil {
+		return err
+	}
+
+	for index, row := range batch {
+		if errs[index] != nil {
+			result.Fail(row.Line, row.User.Email, errs[index])
+			continue
+		}
+		result.Imported++
+	}
+
+	return nil
+}
+
+// prepareImportedUser validates the imported user, hashing the password it informs after checking
+// it with the Policy. Users imported without a password have to reset it before the first login.
+func prepareImportedUser(user *users.User) *resterrors.RestErr {
+	if err := user.ValidateImport(); err != nil {
+		return err
+	}
+
+	now := dateutils.GetNowDBString()
+	if user.DateCreated == "" {
+		user.DateCreated = now
+	}
+	user.DateStatusChanged = now
+	user.DatePasswordChanged = now
+
+	if user.Password == "" {
+		return nil
+	}
+
+	if err := users.Policy.Validate("password", user.Password, user); err != nil {
+		return err
+	}
+
+	hash, hashErr := cryptoutils.Passwords.Hash(user.Password)
+	if hashErr != nil {
+		logger.Error("Error when trying to hash the user password.", hashErr)
+		return resterrors.NewInternalServerError("Error when trying to hash the user password.", errors.New("crypto error"))
+	}
+	user.Password = hash
+
+	return nil
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"time"
+
+	"github.com/migueloli/bookstore_users-api/domain/users"
+	"github.com/migueloli/bookstore_users-api/metrics"
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+const (
+	usersServiceName = "users"
+)
+
+type instrumentedUsersService struct {
+	next usersServiceInterface
+}
+
+// NewInstrumentedUsersService wraps the usersServiceInterface counting and timing its calls by
+// method, and counting the logins by result.
+func NewInstrumentedUsersService(next usersServiceInterface) usersServiceInterface {
+	return &instrumentedUsersService{next: next}
+}
+
+func observeUsersCall(method string, start time.Time, err *resterrors.RestErr) {
+	metrics.ServiceCalls.WithLabelValues(usersServiceName, method, metrics.Result(err)).Inc()
+	metrics.ServiceCallDuration.WithLabelValues(usersServiceName, method).Observe(time.Since(start).Seconds())
+}
+
+func (s *instrumentedUsersService) CreateUser(user users.User, actor users.AuditActor) (*users.User, *resterrors.RestErr) {
+	start := time.Now()
+	result, err := s.next.CreateUser(user, actor)
+	observeUsersCall("CreateUser", start, err)
+	return result, err
+}
+
+func (s *instrumentedUsersService) CreateUsers(request users.BatchCreateRequest, actor users.AuditActor) (*users.BatchCreateResult, *resterrors.RestErr) {
+	start := time.Now()
+	result, err := s.next.CreateUsers(request, actor)
+	observeUsersCall("CreateUsers", start, err)
+	return result, err
+}
+
+func (s *instrumentedUsersService) GetUser(userID int64) (*users.User, *resterrors.RestErr) {
+	start := time.Now()
+	result, err := s.next.GetUser(userID)
+	observeUsersCall("GetUser", start, err)
+	return result, err
+}
+
+func (s *instrumentedUsersService) GetUsers(userIDs []int64) (*users.BatchLookupResult, *resterrors.RestErr) {
+	start := time.Now()
+	result, err := s.next.GetUsers(userIDs)
+	observeUsersCall("GetUsers", start, err)
+	return result, err
+}
+
+func (s *instrumentedUsersService) UpdateUser(user users.User, ifMatch string, actor users.AuditActor) (*users.User, *resterrors.RestErr) {
+	start := time.Now()
+	result, err := s.next.UpdateUser(user, ifMatch, actor)
+	observeUsersCall("UpdateUser", start, err)
+	return result, err
+}
+
+func (s *instrumentedUsersService) PatchUser(userID int64, patch users.Patch, ifMatch string, actor users.AuditActor) (*users.User, *resterrors.RestErr) {
+	start := time.Now()
+	result, err := s.next.PatchUser(userID, patch, ifMatch, actor)
+	observeUsersCall("PatchUser", start, err)
+	return result, err
+}
+
+func (s *instrumentedUsersService) DeleteUser(userID int64, ifMatch string, actor users.AuditActor) *resterrors.RestErr {
+	start := time.Now()
+	err := s.next.DeleteUser(userID, ifMatch, actor)
+	observeUsersCall("DeleteUser", start, err)
+	return err
+}
+
+func (s *instrumentedUsersService) RestoreUser(userID int64, actor users.AuditActor) (*users.User, *resterrors.RestErr) {
+	start := time.Now()
+	result, err := s.next.RestoreUser(userID, actor)
+	observeUsersCall("RestoreUser", start, err)
+	return result, err
+}
+
+func (s *instrumentedUsersService) ChangeStatus(userID int64, operation string, request users.StatusChangeRequest, actor users.AuditActor) (*users.User, *resterrors.RestErr) {
+	start := time.Now()
+	result, err := s.next.ChangeStatus(userID, operation, request, actor)
+	observeUsersCall("ChangeStatus", start, err)
+	return result, err
+}
+
+func (s *instrumentedUsersService) PurgeDeletedUsers(retention time.Duration) (int64, *resterrors.RestErr) {
+	start := time.Now()
+	result, err := s.next.PurgeDeletedUsers(retention)
+	observeUsersCall("PurgeDeletedUsers", start, err)
+	return result, err
+}
+
+func (s *instrumentedUsersService) SearchUser(request users.SearchRequest) (*users.SearchResult, *resterrors.RestErr) {
+	start := time.Now()
+	result, err := s.next.SearchUser(request)
+	observeUsersCall("SearchUser", start, err)
+	return result, err
+}
+
+func (s *instrumentedUsersService) GetAudit(request users.AuditRequest) (*users.AuditResult, *resterrors.RestErr) {
+	start := time.Now()
+	result, err := s.next.GetAudit(request)
+	observeUsersCall("GetAudit", start, err)
+	return result, err
+}
+
+func (s *instrumentedUsersService) LoginUser(request users.UserLoginRequest, actor users.AuditActor) (*users.User, *resterrors.RestErr) {
+	start := time.Now()
+	result, err := s.next.LoginUser(request, actor)
+	observeUsersCall("LoginUser", start, err)
+
+	login := metrics.LoginSuccess
+	if err != nil {
+		login = metrics.LoginFailure
+	}
+	metrics.Logins.WithLabelValues(login).Inc()
+
+	return result, err
+}
+
+func (s *instrumentedUsersService) VerifyEmail(token string, actor users.AuditActor) *resterrors.RestErr {
+	start := time.Now()
+	err := s.next.VerifyEmail(token, actor)
+	observeUsersCall("VerifyEmail", start, err)
+	return err
+}
+
+func (s *instrumentedUsersService) ResendVerification(request users.ResendVerificationRequest) *resterrors.RestErr {
+	start := time.Now()
+	err := s.next.ResendVerification(request)
+	observeUsersCall("ResendVerification", start, err)
+	return err
+}
+
+func (s *instrumentedUsersService) UnlockUser(userID int64, actor users.AuditActor) *resterrors.RestErr {
+	start := time.Now()
+	err := s.next.UnlockUser(userID, actor)
+	observeUsersCall("UnlockUser", start, err)
+	return err
+}
@@ -1,44 +1,169 @@
 package services
 
 import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
 	"github.com/migueloli/bookstore_users-api/domain/users"
+	"github.com/migueloli/bookstore_users-api/logger"
+	"github.com/migueloli/bookstore_users-api/notifications"
 	"github.com/migueloli/bookstore_users-api/utils/cryptoutils"
 	"github.com/migueloli/bookstore_users-api/utils/dateutils"
+	"github.com/migueloli/bookstore_users-api/utils/resterrorsutils"
 	"github.com/migueloli/bookstore_utils-go/resterrors"
 )
 
+const (
+	emailVerificationTokenTTL       = 24 * time.Hour
+	emailVerificationResendInterval = time.Minute
+)
+
 var (
-	// UsersService is the access point to the usersServiceInterface as usersService struct.
-	UsersService usersServiceInterface = &usersService{}
+	// UsersService is the access point to the usersServiceInterface, configured by the application
+	// with the repositories and notifier in use.
+	UsersService usersServiceInterface
 )
 
-type usersService struct{}
+type usersService struct {
+	repository    users.UserRepository
+	verifications users.EmailVerificationRepository
+	notifier      notifications.Notifier
+	loginAttempts users.LoginAttempts
+}
 
 type usersServiceInterface interface {
-	CreateUser(users.User) (*users.User, *resterrors.RestErr)
+	CreateUser(users.User, users.AuditActor) (*users.User, *resterrors.RestErr)
+	CreateUsers(users.BatchCreateRequest, users.AuditActor) (*users.BatchCreateResult, *resterrors.RestErr)
 	GetUser(int64) (*users.User, *resterrors.RestErr)
-	UpdateUser(bool, users.User) (*users.User, *resterrors.RestErr)
-	DeleteUser(int64) *resterrors.RestErr
-	SearchUser(string) (users.Users, *resterrors.RestErr)
-	LoginUser(users.UserLoginRequest) (*users.User, *resterrors.RestErr)
+	GetUsers([]int64) (*users.BatchLookupResult, *resterrors.RestErr)
+	UpdateUser(users.User, string, users.AuditActor) (*users.User, *resterrors.RestErr)
+	PatchUser(int64, users.Patch, string, users.AuditActor) (*users.User, *resterrors.RestErr)
+	DeleteUser(int64, string, users.AuditActor) *resterrors.RestErr
+	RestoreUser(int64, users.AuditActor) (*users.User, *resterrors.RestErr)
+	ChangeStatus(int64, string, users.StatusChangeRequest, users.AuditActor) (*users.User, *resterrors.RestErr)
+	PurgeDeletedUsers(time.Duration) (int64, *resterrors.RestErr)
+	SearchUser(users.SearchRequest) (*users.SearchResult, *resterrors.RestErr)
+	GetAudit(users.AuditRequest) (*users.AuditResult, *resterrors.RestErr)
+	LoginUser(users.UserLoginRequest, users.AuditActor) (*users.User, *resterrors.RestErr)
+	VerifyEmail(string, users.AuditActor) *resterrors.RestErr
+	ResendVerification(users.ResendVerificationRequest) *resterrors.RestErr
+	UnlockUser(int64, users.AuditActor) *resterrors.RestErr
+}
+
+// NewUsersService creates the usersServiceInterface persisting the users with the repositories,
+// sending the e-mail verification tokens with the notifier and throttling the logins.
+func NewUsersService(repository users.UserRepository, verifications users.EmailVerificationRepository, notifier notifications.Notifier, loginAttempts users.LoginAttempts) usersServiceInterface {
+	return &usersService{
+		repository:    repository,
+		verifications: verifications,
+		notifier:      notifier,
+		loginAttempts: loginAttempts,
+	}
 }
 
 // CreateUser is a service to handle the user creation
-func (s *usersService) CreateUser(user users.User) (*users.User, *resterrors.RestErr) {
-	if err := user.Validate(); err != nil {
+func (s *usersService) CreateUser(user users.User, actor users.AuditActor) (*users.User, *resterrors.RestErr) {
+	if err := prepareNewUser(&user); err != nil {
 		return nil, err
 	}
 
-	user.Status = users.StatusActive
-	user.DateCreated = dateutils.GetNowDBString()
-	user.Password = cryptoutils.GetMd5(user.Password)
-	if err := user.Save(); err != nil {
+	entry := users.NewAuditEntry(actor, users.AuditActionCreate, 0)
+	entry.Diff(nil, &user)
+	if err := s.repository.Save(&user, entry); err != nil {
 		return nil, err
 	}
 
+	// The user is already created, a failure here is recovered by resending the verification.
+	if err := s.sendVerification(&user); err != nil {
+		logger.Error("Error when trying to send the e-mail verification token.", errors.New(err.Message))
+	}
+
 	return &user, nil
 }
 
+// CreateUsers is a service to handle the creation of a batch of users. The invalid users fail on
+// their own and the valid ones are saved together, so only a database error fails the batch.
+func (s *usersService) CreateUsers(request users.BatchCreateRequest, actor users.AuditActor) (*users.BatchCreateResult, *resterrors.RestErr) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	result := &users.BatchCreateResult{Results: make([]users.BatchItemResult, len(request.Users))}
+	valid := make(users.Users, 0, len(request.Users))
+	entries := make([]*users.AuditEntry, 0, len(request.Users))
+	positions := make([]int, 0, len(request.Users))
+	for index := range request.Users {
+		result.Results[index].Index = index
+		if err := prepareNewUser(&request.Users[index]); err != nil {
+			result.Results[index].Error = err
+			continue
+		}
+
+		entry := users.NewAuditEntry(actor, users.AuditActionCreate, 0)
+		entry.Diff(nil, &request.Users[index])
+		valid = append(valid, request.Users[index])
+		entries = append(entries, entry)
+		positions = append(positions, index)
+	}
+
+	if len(valid) > 0 {
+		errs, err := s.repository.SaveAll(valid, entries)
+		if err != nil {
+			return nil, err
+		}
+
+		for position, index := range positions {
+			if errs[position] != nil {
+				result.Results[index].Error = errs[position]
+				continue
+			}
+
+			user := &valid[position]
+			result.Results[index].User = user
+			if err := s.sendVerification(user); err != nil {
+				logger.Error("Error when trying to send the e-mail verification token.", errors.New(err.Message))
+			}
+		}
+	}
+
+	for _, item := range result.Results {
+		if item.Error != nil {
+			result.Failed++
+		} else {
+			result.Created++
+		}
+	}
+
+	return result, nil
+}
+
+// prepareNewUser validates the user and its password, filling the fields of a new pending user.
+func prepareNewUser(user *users.User) *resterrors.RestErr {
+	if err := user.Validate(); err != nil {
+		return err
+	}
+
+	if err := users.Policy.Validate("password", user.Password, user); err != nil {
+		return err
+	}
+
+	user.Status = users.StatusPending
+	user.DateCreated = dateutils.GetNowDBString()
+	user.DateStatusChanged = user.DateCreated
+	user.DatePasswordChanged = user.DateCreated
+	hash, hashErr := cryptoutils.Passwords.Hash(user.Password)
+	if hashErr != nil {
+		logger.Error("Error when trying to hash the user password.", hashErr)
+		return resterrors.NewInternalServerError("Error when trying to hash the user password.", errors.New("crypto error"))
+	}
+	user.Password = hash
+
+	return nil
+}
+
 // GetUser is a service to handle the user recover
 func (s *usersService) GetUser(userID int64) (*users.User, *resterrors.RestErr) {
 	if userID <= 0 {
@@ -46,71 +171,411 @@ func (s *usersService) GetUser(userID int64) (*users.User, *resterrors.RestErr)
 	}
 
 	result := &users.User{ID: userID}
-	if err := result.Get(); err != nil {
+	if err := s.repository.Get(result); err != nil {
 		return nil, err
 	}
 
 	return result, nil
 }
 
-// UpdateUser is a service to handle the user updating
-func (s *usersService) UpdateUser(isPartial bool, user users.User) (*users.User, *resterrors.RestErr) {
-	current, err := s.GetUser(user.ID)
+// GetUsers is a service to handle the recover of many users at once, informing the IDs not found.
+func (s *usersService) GetUsers(userIDs []int64) (*users.BatchLookupResult, *resterrors.RestErr) {
+	found, err := s.repository.GetAll(userIDs)
 	if err != nil {
 		return nil, err
 	}
 
-	if isPartial {
-		if user.FirstName != "" {
-			current.FirstName = user.FirstName
+	exists := make(map[int64]bool, len(found))
+	for _, user := range found {
+		exists[user.ID] = true
+	}
+
+	result := &users.BatchLookupResult{Results: found, Missing: make([]int64, 0)}
+	for _, userID := range userIDs {
+		if !exists[userID] {
+			result.Missing = append(result.Missing, userID)
 		}
-		if user.LastName != "" {
-			current.LastName = user.LastName
+	}
+
+	return result, nil
+}
+
+// UpdateUser is a service to handle the user updating, only while the user matches the If-Match
+// header. The write itself is conditional on the version read, so concurrent updates can't clobber
+// each other.
+func (s *usersService) UpdateUser(user users.User, ifMatch string, actor users.AuditActor) (*users.User, *resterrors.RestErr) {
+	current, err := s.GetUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !current.MatchesIfMatch(ifMatch) {
+		return nil, resterrorsutils.NewPreconditionFailedError(fmt.Sprintf("User %d doesn't match the If-Match header.", current.ID))
+	}
+
+	before := *current
+	current.FirstName = user.FirstName
+	current.LastName = user.LastName
+	current.Email = user.Email
+
+	return s.saveUpdate(&before, current, actor)
+}
+
+// PatchUser is a service to apply the partial change to the user, only while the user matches the
+// If-Match header.
+func (s *usersService) PatchUser(userID int64, patch users.Patch, ifMatch string, actor users.AuditActor) (*users.User, *resterrors.RestErr) {
+	current, err := s.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !current.MatchesIfMatch(ifMatch) {
+		return nil, resterrorsutils.NewPreconditionFailedError(fmt.Sprintf("User %d doesn't match the If-Match header.", current.ID))
+	}
+
+	before := *current
+	if err := patch.Apply(current); err != nil {
+		return nil, err
+	}
+
+	return s.saveUpdate(&before, current, actor)
+}
+
+// saveUpdate writes the changed user. A new e-mail address has to be verified again, so the user
+// goes back to pending and can't sign in until it is.
+func (s *usersService) saveUpdate(before *users.User, user *users.User, actor users.AuditActor) (*users.User, *resterrors.RestErr) {
+	if err := user.Validate(); err != nil {
+		return nil, err
+	}
+
+	emailChanged := user.Email != before.Email
+	if emailChanged {
+		// Reactivating a suspended or banned user would skip the verification.
+		if err := before.CheckRestrictedStatus(); err != nil {
+			return nil, err
 		}
-		if user.Email != "" {
-			current.Email = user.Email
+
+		user.Status = users.StatusPending
+		user.StatusReason = ""
+		user.DateStatusChanged = dateutils.GetNowDBString()
+
+		// The tokens sent to the previous address can't verify the new one.
+		if err := s.verifications.Revoke(user.ID, user.DateStatusChanged); err != nil {
+			return nil, err
 		}
-	} else {
-		current.FirstName = user.FirstName
-		current.LastName = user.LastName
-		current.Email = user.Email
 	}
 
-	if err := current.Validate(); err != nil {
+	entry := users.NewAuditEntry(actor, users.AuditActionUpdate, user.ID)
+	entry.Diff(before, user)
+	if err := s.repository.Update(user, entry); err != nil {
 		return nil, err
 	}
 
-	if err := current.Update(); err != nil {
-		return nil, err
+	if emailChanged {
+		// The user is already updated, a failure here is recovered by resending the verification.
+		if err := s.sendVerification(user); err != nil {
+			logger.Error("Error when trying to send the e-mail verification token.", errors.New(err.Message))
+		}
 	}
 
-	return &user, nil
+	return user, nil
 }
 
-// DeleteUser is a service to handle the user recover
-func (s *usersService) DeleteUser(userID int64) *resterrors.RestErr {
+// DeleteUser is a service to handle the user deletion, only while the user matches the If-Match header.
+func (s *usersService) DeleteUser(userID int64, ifMatch string, actor users.AuditActor) *resterrors.RestErr {
 	if userID <= 0 {
 		return resterrors.NewBadRequestError("User ID has to be greater than 0.")
 	}
 
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return err
+	}
+
+	if !user.MatchesIfMatch(ifMatch) {
+		return resterrorsutils.NewPreconditionFailedError(fmt.Sprintf("User %d doesn't match the If-Match header.", user.ID))
+	}
+
+	if _, err := users.NextStatus(user.Status, users.OperationDelete); err != nil {
+		return err
+	}
+
+	before := *user
+	user.Status = users.StatusDeleted
+	user.DateDeleted = dateutils.GetNowDBString()
+	entry := users.NewAuditEntry(actor, users.AuditActionDelete, user.ID)
+	entry.Diff(&before, user)
+	return s.repository.Delete(user, entry)
+}
+
+// RestoreUser is a service to bring back the deleted user with the status it had.
+func (s *usersService) RestoreUser(userID int64, actor users.AuditActor) (*users.User, *resterrors.RestErr) {
+	if userID <= 0 {
+		return nil, resterrors.NewBadRequestError("User ID has to be greater than 0.")
+	}
+
 	user := &users.User{ID: userID}
-	return user.Delete()
+	if err := s.repository.Restore(user, users.NewAuditEntry(actor, users.AuditActionRestore, userID)); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// ChangeStatus is a service to apply an admin operation of the status state machine to the user.
+func (s *usersService) ChangeStatus(userID int64, operation string, request users.StatusChangeRequest, actor users.AuditActor) (*users.User, *resterrors.RestErr) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := users.NextStatus(user.Status, operation)
+	if err != nil {
+		return nil, err
+	}
+
+	before := *user
+	user.Status = status
+	user.StatusReason = request.Reason
+	user.DateStatusChanged = dateutils.GetNowDBString()
+	entry := users.NewAuditEntry(actor, operation, user.ID)
+	entry.Diff(&before, user)
+	if err := s.repository.UpdateStatus(user, entry); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// PurgeDeletedUsers is a service to remove for good the users deleted longer than the retention ago.
+func (s *usersService) PurgeDeletedUsers(retention time.Duration) (int64, *resterrors.RestErr) {
+	entry := users.NewAuditEntry(users.SystemActor, users.AuditActionPurge, 0)
+	return s.repository.Purge(dateutils.GetDBString(dateutils.GetNow().Add(-retention)), entry)
 }
 
 // SearchUser is a service to handle the user recover using params
-func (s *usersService) SearchUser(status string) (users.Users, *resterrors.RestErr) {
-	dao := &users.User{}
-	return dao.FindByStatus(status)
+func (s *usersService) SearchUser(request users.SearchRequest) (*users.SearchResult, *resterrors.RestErr) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	return s.repository.Search(request)
+}
+
+// GetAudit is a service to handle the recover of a page of the audit trail of the user.
+func (s *usersService) GetAudit(request users.AuditRequest) (*users.AuditResult, *resterrors.RestErr) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	return s.repository.GetAudit(request)
 }
 
 // LoginUser is a service to handle the user login
-func (s *usersService) LoginUser(request users.UserLoginRequest) (*users.User, *resterrors.RestErr) {
+func (s *usersService) LoginUser(request users.UserLoginRequest, actor users.AuditActor) (*users.User, *resterrors.RestErr) {
 	dao := &users.User{
-		Email:    request.Email,
-		Password: cryptoutils.GetMd5(request.Password),
+		Email: strings.TrimSpace(strings.ToLower(request.Email)),
+	}
+
+	accountKey := users.LoginAttemptAccountPrefix + dao.Email
+	ipKey := users.LoginAttemptIPPrefix + request.ClientIP
+	if err := s.checkLoginAttempts(accountKey, ipKey); err != nil {
+		return nil, err
+	}
+
+	if err := s.repository.FindByEmail(dao); err != nil {
+		if err.Status == http.StatusNotFound {
+			// An unknown e-mail takes as long as a wrong password, not telling which ones exist.
+			cryptoutils.Passwords.VerifyDummy(request.Password)
+			s.failLoginAttempt(accountKey, ipKey)
+		}
+		return nil, err
 	}
-	if err := dao.FindByEmailPassword(); err != nil {
+
+	ok, rehash, verifyErr := cryptoutils.Passwords.Verify(request.Password, dao.Password)
+	if verifyErr != nil {
+		logger.Error("Error when trying to verify the user password.", verifyErr)
+	}
+	if !ok {
+		s.failLoginAttempt(accountKey, ipKey)
+		return nil, resterrors.NewNotFoundError("Invalid user credentials.")
+	}
+
+	// Only the account is forgotten, a valid login must not clear the guesses of the IP.
+	s.loginAttempts.Accounts.Succeed(accountKey)
+
+	if err := dao.CheckLoginStatus(); err != nil {
 		return nil, err
 	}
+
+	if rehash {
+		// The login proves who the caller is.
+		actor.UserID = dao.ID
+		s.rehashPassword(dao, request.Password, actor)
+	}
+
 	return dao, nil
 }
+
+// UnlockUser is a service to clear the failed login attempts and the lockout of the user account,
+// recording the unlock in the audit trail.
+func (s *usersService) UnlockUser(userID int64, actor users.AuditActor) *resterrors.RestErr {
+	user, err := s.GetUser(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repository.Audit(users.NewAuditEntry(actor, users.AuditActionUnlock, user.ID)); err != nil {
+		return err
+	}
+
+	s.loginAttempts.Accounts.Unlock(users.LoginAttemptAccountPrefix + user.Email)
+	return nil
+}
+
+// checkLoginAttempts refuses the login while the client IP or the account has to wait.
+func (s *usersService) checkLoginAttempts(accountKey string, ipKey string) *resterrors.RestErr {
+	if wait, _ := s.loginAttempts.IPs.Check(ipKey); wait > 0 {
+		restErr := resterrorsutils.NewTooManyRequestsError("Too many failed login attempts, try again later.")
+		restErr.Causes = []interface{}{users.NewRetryAfter(wait)}
+		return restErr
+	}
+
+	wait, locked := s.loginAttempts.Accounts.Check(accountKey)
+	if locked {
+		restErr := resterrorsutils.NewLockedError("Account temporarily locked after too many failed login attempts.")
+		restErr.Causes = []interface{}{users.NewRetryAfter(wait)}
+		return restErr
+	}
+	if wait > 0 {
+		restErr := resterrorsutils.NewTooManyRequestsError("Too many failed login attempts, try again later.")
+		restErr.Causes = []interface{}{users.NewRetryAfter(wait)}
+		return restErr
+	}
+
+	return nil
+}
+
+func (s *usersService) failLoginAttempt(accountKey string, ipKey string) {
+	s.loginAttempts.Accounts.Fail(accountKey)
+	s.loginAttempts.IPs.Fail(ipKey)
+}
+
+// rehashPassword upgrades the stored hash to the current algorithm, keeping the password change
+// date. A failure here must not prevent the login, the upgrade is tried again on the next one.
+func (s *usersService) rehashPassword(user *users.User, password string, actor users.AuditActor) {
+	hash, err := cryptoutils.Passwords.Hash(password)
+	if err != nil {
+		logger.Error("Error when trying to rehash the user password.", err)
+		return
+	}
+
+	before := *user
+	user.Password = hash
+	entry := users.NewAuditEntry(actor, users.AuditActionPasswordRehash, user.ID)
+	entry.Diff(&before, user)
+	if restErr := s.repository.UpdatePassword(user, entry); restErr != nil {
+		logger.Error("Error when trying to store the rehashed user password.", errors.New(restErr.Message))
+	}
+}
+
+// VerifyEmail is a service to consume an e-mail verification token activating the pending user.
+func (s *usersService) VerifyEmail(token string, actor users.AuditActor) *resterrors.RestErr {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return resterrors.NewBadRequestError("Invalid or expired e-mail verification token.")
+	}
+
+	verification := &users.EmailVerificationToken{TokenHash: cryptoutils.HashToken(token)}
+	if err := s.verifications.Consume(verification, dateutils.GetNowDBString()); err != nil {
+		return err
+	}
+
+	user, err := s.GetUser(verification.UserID)
+	if err != nil {
+		return err
+	}
+
+	// Users already verified or barred by an admin keep their status.
+	status, err := users.NextStatus(user.Status, users.OperationVerify)
+	if err != nil {
+		return nil
+	}
+
+	// The token proves who the caller is.
+	actor.UserID = user.ID
+	before := *user
+	user.Status = status
+	user.StatusReason = ""
+	user.DateStatusChanged = dateutils.GetNowDBString()
+	entry := users.NewAuditEntry(actor, users.OperationVerify, user.ID)
+	entry.Diff(&before, user)
+	return s.repository.UpdateStatus(user, entry)
+}
+
+// ResendVerification is a service to send a new e-mail verification token to a pending user,
+// at most once per emailVerificationResendInterval. It doesn't tell if the e-mail is registered.
+func (s *usersService) ResendVerification(request users.ResendVerificationRequest) *resterrors.RestErr {
+	user := &users.User{Email: strings.TrimSpace(strings.ToLower(request.Email))}
+	if user.Email == "" {
+		return resterrors.NewBadRequestError("Invalid e-mail address.")
+	}
+
+	if err := s.repository.FindByEmail(user); err != nil {
+		if err.Status == http.StatusNotFound {
+			return nil
+		}
+		return err
+	}
+
+	if user.Status != users.StatusPending {
+		return nil
+	}
+
+	last, err := s.verifications.GetLastDateCreated(user.ID)
+	if err != nil {
+		return err
+	}
+
+	if last != "" && last > dateutils.GetDBString(dateutils.GetNow().Add(-emailVerificationResendInterval)) {
+		return resterrorsutils.NewTooManyRequestsError("E-mail verification token sent recently, try again later.")
+	}
+
+	return s.sendVerification(user)
+}
+
+// sendVerification issues a new e-mail verification token and sends it to the user.
+func (s *usersService) sendVerification(user *users.User) *resterrors.RestErr {
+	token, tokenErr := cryptoutils.GenerateToken()
+	if tokenErr != nil {
+		logger.Error("Error when trying to generate the e-mail verification token.", tokenErr)
+		return resterrors.NewInternalServerError("Error when trying to generate the e-mail verification token.", errors.New("crypto error"))
+	}
+
+	now := dateutils.GetNow()
+	verification := &users.EmailVerificationToken{
+		UserID:      user.ID,
+		TokenHash:   cryptoutils.HashToken(token),
+		DateCreated: dateutils.GetDBString(now),
+		DateExpires: dateutils.GetDBString(now.Add(emailVerificationTokenTTL)),
+	}
+	if err := s.verifications.Save(verification); err != nil {
+		return err
+	}
+
+	message := notifications.Message{
+		To:      user.Email,
+		Subject: "E-mail verification",
+		Body:    fmt.Sprintf("Use the token %s to verify your e-mail address. It expires at %s UTC.", token, verification.DateExpires),
+	}
+	if err := s.notifier.Notify(message); err != nil {
+		logger.Error("Error when trying to send the e-mail verification token.", err)
+		return resterrors.NewInternalServerError("Error when trying to send the e-mail verification token.", errors.New("notification error"))
+	}
+
+	return nil
+}
@@ -0,0 +1,609 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/migueloli/bookstore_users-api/domain/users"
+	"github.com/migueloli/bookstore_users-api/notifications"
+	"github.com/migueloli/bookstore_users-api/utils/cryptoutils"
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+const testPassword = "Xx9!longpassword"
+
+// testAccountLoginThrottle locks the account on the third failure without delaying the first ones.
+var testAccountLoginThrottle = users.LoginThrottleConfig{
+	FreeAttempts:     10,
+	BaseDelay:        time.Millisecond,
+	MaxDelay:         time.Millisecond,
+	LockoutThreshold: 3,
+	LockoutDuration:  time.Minute,
+	ResetAfter:       time.Hour,
+}
+
+// newTestUsersService creates the service on the memory repositories, returning the users
+// repository to check what was stored.
+func newTestUsersService() (usersServiceInterface, users.UserRepository) {
+	return newTestUsersServiceNotifying(notifications.NewLogNotifier())
+}
+
+func newTestUsersServiceNotifying(notifier notifications.Notifier) (usersServiceInterface, users.UserRepository) {
+	repository := users.NewMemoryRepository()
+	loginAttempts := users.LoginAttempts{
+		Accounts: users.NewMemoryLoginAttemptTracker(testAccountLoginThrottle),
+		IPs:      users.NewMemoryLoginAttemptTracker(users.DefaultIPLoginThrottle),
+	}
+
+	return NewUsersService(repository, users.NewEmailVerificationMemoryRepository(), notifier, loginAttempts), repository
+}
+
+// createTestUser creates the user with the test password, activated unless the status is pending.
+func createTestUser(t *testing.T, service usersServiceInterface, email string, status string) *users.User {
+	t.Helper()
+
+	user, err := service.CreateUser(users.User{FirstName: "first", LastName: "last", Email: email, Password: testPassword}, users.SystemActor)
+	if err != nil {
+		t.Fatalf("creating %s: %s", email, err.Message)
+	}
+
+	operations := map[string][]string{
+		users.StatusPending:   {},
+		users.StatusActive:    {users.OperationActivate},
+		users.StatusSuspended: {users.OperationActivate, users.OperationSuspend},
+		users.StatusBanned:    {users.OperationBan},
+	}
+	for _, operation := range operations[status] {
+		if user, err = service.ChangeStatus(user.ID, operation, users.StatusChangeRequest{Reason: "test"}, users.SystemActor); err != nil {
+			t.Fatalf("applying %s to %s: %s", operation, email, err.Message)
+		}
+	}
+
+	return user
+}
+
+func login(service usersServiceInterface, email string, password string) (*users.User, int) {
+	user, err := service.LoginUser(users.UserLoginRequest{Email: email, Password: password, ClientIP: "127.0.0.1"}, users.SystemActor)
+	if err != nil {
+		return nil, err.Status
+	}
+
+	return user, http.StatusOK
+}
+
+func TestCreateUserUniqueEmail(t *testing.T) {
+	service, _ := newTestUsersService()
+	createTestUser(t, service, "taken@example.com", users.StatusPending)
+
+	tests := []struct {
+		name   string
+		email  string
+		status int
+	}{
+		{name: "new e-mail", email: "new@example.com", status: http.StatusOK},
+		{name: "taken e-mail", email: "taken@example.com", status: http.StatusBadRequest},
+		{name: "taken e-mail in another case", email: " Taken@Example.com ", status: http.StatusBadRequest},
+		{name: "empty e-mail", email: "", status: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status := http.StatusOK
+			if _, err := service.CreateUser(users.User{FirstName: "first", LastName: "last", Email: test.email, Password: testPassword}, users.SystemActor); err != nil {
+				status = err.Status
+			}
+
+			if status != test.status {
+				t.Errorf("got status %d, want %d", status, test.status)
+			}
+		})
+	}
+}
+
+func TestCreateUsersUniqueEmail(t *testing.T) {
+	service, _ := newTestUsersService()
+	createTestUser(t, service, "taken@example.com", users.StatusPending)
+
+	request := users.BatchCreateRequest{Users: users.Users{
+		{FirstName: "first", LastName: "last", Email: "one@example.com", Password: testPassword},
+		{FirstName: "first", LastName: "last", Email: "taken@example.com", Password: testPassword},
+		{FirstName: "first", LastName: "last", Email: "two@example.com", Password: testPassword},
+	}}
+	result, err := service.CreateUsers(request, users.SystemActor)
+	if err != nil {
+		t.Fatalf("creating the batch: %s", err.Message)
+	}
+
+	if result.Created != 2 || result.Failed != 1 {
+		t.Fatalf("got %d created and %d failed, want 2 and 1", result.Created, result.Failed)
+	}
+	if result.Results[1].Error == nil || result.Results[1].Error.Status != http.StatusBadRequest {
+		t.Errorf("the taken e-mail should fail with %d, got %+v", http.StatusBadRequest, result.Results[1].Error)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	service, _ := newTestUsersService()
+	active := createTestUser(t, service, "active@example.com", users.StatusActive)
+	deleted := createTestUser(t, service, "deleted@example.com", users.StatusActive)
+	if err := service.DeleteUser(deleted.ID, "", users.SystemActor); err != nil {
+		t.Fatalf("deleting the user: %s", err.Message)
+	}
+
+	const missingID = 999
+	tests := []struct {
+		name string
+		call func() int
+	}{
+		{name: "get missing user", call: func() int {
+			_, err := service.GetUser(missingID)
+			return statusOf(err)
+		}},
+		{name: "get deleted user", call: func() int {
+			_, err := service.GetUser(deleted.ID)
+			return statusOf(err)
+		}},
+		{name: "update missing user", call: func() int {
+			_, err := service.UpdateUser(users.User{ID: missingID, Email: "missing@example.com"}, "", users.SystemActor)
+			return statusOf(err)
+		}},
+		{name: "delete missing user", call: func() int {
+			return statusOf(service.DeleteUser(missingID, "", users.SystemActor))
+		}},
+		{name: "delete deleted user", call: func() int {
+			return statusOf(service.DeleteUser(deleted.ID, "", users.SystemActor))
+		}},
+		{name: "restore user not deleted", call: func() int {
+			_, err := service.RestoreUser(active.ID, users.SystemActor)
+			return statusOf(err)
+		}},
+		{name: "change status of missing user", call: func() int {
+			_, err := service.ChangeStatus(missingID, users.OperationSuspend, users.StatusChangeRequest{Reason: "test"}, users.SystemActor)
+			return statusOf(err)
+		}},
+		{name: "unlock missing user", call: func() int {
+			return statusOf(service.UnlockUser(missingID, users.SystemActor))
+		}},
+		{name: "login with unknown e-mail", call: func() int {
+			_, status := login(service, "unknown@example.com", testPassword)
+			return status
+		}},
+		{name: "login with deleted user", call: func() int {
+			_, status := login(service, "deleted@example.com", testPassword)
+			return status
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if status := test.call(); status != http.StatusNotFound {
+				t.Errorf("got status %d, want %d", status, http.StatusNotFound)
+			}
+		})
+	}
+}
+
+func TestGetUsersMissing(t *testing.T) {
+	service, _ := newTestUsersService()
+	user := createTestUser(t, service, "user@example.com", users.StatusActive)
+
+	result, err := service.GetUsers([]int64{999, user.ID})
+	if err != nil {
+		t.Fatalf("getting the users: %s", err.Message)
+	}
+
+	if len(result.Results) != 1 || result.Results[0].ID != user.ID {
+		t.Errorf("got results %+v, want only the user %d", result.Results, user.ID)
+	}
+	if len(result.Missing) != 1 || result.Missing[0] != 999 {
+		t.Errorf("got missing %v, want [999]", result.Missing)
+	}
+}
+
+func TestSearchStatusFilter(t *testing.T) {
+	service, _ := newTestUsersService()
+	createTestUser(t, service, "pending@example.com", users.StatusPending)
+	createTestUser(t, service, "active1@example.com", users.StatusActive)
+	createTestUser(t, service, "active2@example.com", users.StatusActive)
+	createTestUser(t, service, "suspended@example.com", users.StatusSuspended)
+	createTestUser(t, service, "banned@example.com", users.StatusBanned)
+	deleted := createTestUser(t, service, "deleted@example.com", users.StatusActive)
+	if err := service.DeleteUser(deleted.ID, "", users.SystemActor); err != nil {
+		t.Fatalf("deleting the user: %s", err.Message)
+	}
+
+	tests := []struct {
+		status string
+		emails []string
+	}{
+		{status: "", emails: []string{"pending@example.com", "active1@example.com", "active2@example.com", "suspended@example.com", "banned@example.com"}},
+		{status: users.StatusPending, emails: []string{"pending@example.com"}},
+		{status: users.StatusActive, emails: []string{"active1@example.com", "active2@example.com"}},
+		{status: " Suspended ", emails: []string{"suspended@example.com"}},
+		{status: users.StatusBanned, emails: []string{"banned@example.com"}},
+		{status: users.StatusDeleted, emails: []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run("status "+test.status, func(t *testing.T) {
+			result, err := service.SearchUser(users.SearchRequest{Status: test.status})
+			if err != nil {
+				t.Fatalf("searching: %s", err.Message)
+			}
+
+			if got := emailsOf(result.Results); strings.Join(got, ",") != strings.Join(test.emails, ",") {
+				t.Errorf("got %v, want %v", got, test.emails)
+			}
+			if result.Total != int64(len(test.emails)) {
+				t.Errorf("got total %d, want %d", result.Total, len(test.emails))
+			}
+		})
+	}
+}
+
+func TestSearchCursor(t *testing.T) {
+	service, _ := newTestUsersService()
+	emails := []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com"}
+	for _, email := range emails {
+		createTestUser(t, service, email, users.StatusActive)
+	}
+
+	tests := []struct {
+		name  string
+		order string
+		want  []string
+	}{
+		{name: "ascending", order: users.OrderAsc, want: emails},
+		{name: "descending", order: users.OrderDesc, want: []string{"e@example.com", "d@example.com", "c@example.com", "b@example.com", "a@example.com"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := make([]string, 0)
+			cursor := ""
+			for pages := 0; pages < len(emails); pages++ {
+				result, err := service.SearchUser(users.SearchRequest{Sort: users.SortID, Order: test.order, Limit: 2, Cursor: cursor})
+				if err != nil {
+					t.Fatalf("searching page %d: %s", pages, err.Message)
+				}
+
+				got = append(got, emailsOf(result.Results)...)
+				if cursor = result.NextCursor; cursor == "" {
+					break
+				}
+			}
+
+			if strings.Join(got, ",") != strings.Join(test.want, ",") {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+
+	t.Run("cursor of another sort", func(t *testing.T) {
+		result, err := service.SearchUser(users.SearchRequest{Sort: users.SortID, Limit: 2})
+		if err != nil {
+			t.Fatalf("searching: %s", err.Message)
+		}
+
+		_, err = service.SearchUser(users.SearchRequest{Sort: users.SortLastName, Limit: 2, Cursor: result.NextCursor})
+		if statusOf(err) != http.StatusBadRequest {
+			t.Errorf("got status %d, want %d", statusOf(err), http.StatusBadRequest)
+		}
+	})
+}
+
+func TestPatchUser(t *testing.T) {
+	service, _ := newTestUsersService()
+	createTestUser(t, service, "taken@example.com", users.StatusActive)
+
+	tests := []struct {
+		name    string
+		body    string
+		ifMatch func(*users.User) string
+		status  int
+		want    string
+	}{
+		{name: "changes the first name", body: `{"first_name": "Patched"}`, status: http.StatusOK, want: "patched last"},
+		{name: "changes the e-mail", body: `{"email": "Other@Example.com"}`, status: http.StatusOK, want: "first last other@example.com"},
+		{name: "matches the ETag", body: `{"last_name": "etag"}`, ifMatch: (*users.User).ETag, status: http.StatusOK, want: "first etag"},
+		{name: "stale ETag", body: `{"last_name": "stale"}`, ifMatch: func(*users.User) string { return `"stale"` }, status: http.StatusPreconditionFailed},
+		{name: "taken e-mail", body: `{"email": "taken@example.com"}`, status: http.StatusBadRequest},
+		{name: "cleared e-mail", body: `{"email": null}`, status: http.StatusBadRequest},
+	}
+
+	for index, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			user := createTestUser(t, service, fmt.Sprintf("patched%d@example.com", index), users.StatusActive)
+
+			patch, err := users.NewMergePatch([]byte(test.body))
+			if err != nil {
+				t.Fatalf("parsing the patch: %s", err.Message)
+			}
+
+			ifMatch := ""
+			if test.ifMatch != nil {
+				ifMatch = test.ifMatch(user)
+			}
+
+			patched, err := service.PatchUser(user.ID, patch, ifMatch, users.SystemActor)
+			if statusOf(err) != test.status {
+				t.Fatalf("got status %d, want %d", statusOf(err), test.status)
+			}
+
+			stored, getErr := service.GetUser(user.ID)
+			if getErr != nil {
+				t.Fatalf("getting the user: %s", getErr.Message)
+			}
+			if err != nil {
+				if stored.Version != user.Version {
+					t.Errorf("got version %d stored, want %d untouched", stored.Version, user.Version)
+				}
+				return
+			}
+
+			want := test.want
+			if strings.Count(want, " ") == 1 {
+				want += " " + user.Email
+			}
+			if got := stored.FirstName + " " + stored.LastName + " " + stored.Email; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+			if stored.Version != user.Version+1 || patched.Version != stored.Version {
+				t.Errorf("got version %d returned and %d stored, want %d", patched.Version, stored.Version, user.Version+1)
+			}
+		})
+	}
+}
+
+// recordingNotifier keeps the verification tokens sent by e-mail address.
+type recordingNotifier struct {
+	tokens map[string][]string
+}
+
+func (n *recordingNotifier) Notify(message notifications.Message) error {
+	// The body is "Use the token <token> to verify ...".
+	n.tokens[message.To] = append(n.tokens[message.To], strings.Fields(message.Body)[3])
+	return nil
+}
+
+func TestUpdateEmailVerifiesAgain(t *testing.T) {
+	notifier := &recordingNotifier{tokens: make(map[string][]string)}
+	service, _ := newTestUsersServiceNotifying(notifier)
+
+	update := func(user *users.User, email string) (*users.User, *resterrors.RestErr) {
+		return service.UpdateUser(users.User{ID: user.ID, FirstName: user.FirstName, LastName: user.LastName, Email: email}, "", users.SystemActor)
+	}
+
+	t.Run("active user goes back to pending", func(t *testing.T) {
+		user := createTestUser(t, service, "active@example.com", users.StatusActive)
+
+		renamed, err := service.UpdateUser(users.User{ID: user.ID, FirstName: "renamed", LastName: "last", Email: user.Email}, "", users.SystemActor)
+		if err != nil || renamed.Status != users.StatusActive {
+			t.Fatalf("renaming: got %v, %v, want the user still active", renamed, err)
+		}
+
+		updated, err := update(renamed, "moved@example.com")
+		if err != nil {
+			t.Fatalf("changing the e-mail: %s", err.Message)
+		}
+		if updated.Status != users.StatusPending {
+			t.Errorf("got status %s, want %s", updated.Status, users.StatusPending)
+		}
+		if _, status := login(service, "moved@example.com", testPassword); status != http.StatusForbidden {
+			t.Errorf("logging in before the verification: got status %d, want %d", status, http.StatusForbidden)
+		}
+
+		tokens := notifier.tokens["moved@example.com"]
+		if len(tokens) != 1 {
+			t.Fatalf("got %d tokens sent to the new e-mail, want 1", len(tokens))
+		}
+		if err := service.VerifyEmail(tokens[0], users.SystemActor); err != nil {
+			t.Fatalf("verifying the new e-mail: %s", err.Message)
+		}
+		if _, status := login(service, "moved@example.com", testPassword); status != http.StatusOK {
+			t.Errorf("logging in after the verification: got status %d, want %d", status, http.StatusOK)
+		}
+	})
+
+	t.Run("tokens of the previous e-mail are revoked", func(t *testing.T) {
+		user := createTestUser(t, service, "pending@example.com", users.StatusPending)
+
+		if _, err := update(user, "corrected@example.com"); err != nil {
+			t.Fatalf("changing the e-mail: %s", err.Message)
+		}
+
+		if err := service.VerifyEmail(notifier.tokens["pending@example.com"][0], users.SystemActor); statusOf(err) != http.StatusBadRequest {
+			t.Errorf("verifying with the previous token: got status %d, want %d", statusOf(err), http.StatusBadRequest)
+		}
+		if stored, _ := service.GetUser(user.ID); stored.Status != users.StatusPending {
+			t.Errorf("got status %s, want %s", stored.Status, users.StatusPending)
+		}
+	})
+
+	t.Run("restricted user can't change the e-mail", func(t *testing.T) {
+		user := createTestUser(t, service, "suspended@example.com", users.StatusSuspended)
+
+		if _, err := update(user, "escaped@example.com"); statusOf(err) != http.StatusForbidden {
+			t.Errorf("got status %d, want %d", statusOf(err), http.StatusForbidden)
+		}
+		if stored, _ := service.GetUser(user.ID); stored.Email != user.Email || stored.Status != users.StatusSuspended {
+			t.Errorf("got %s %s stored, want the user untouched", stored.Email, stored.Status)
+		}
+	})
+}
+
+func TestLoginRehashesLegacyPassword(t *testing.T) {
+	service, repository := newTestUsersService()
+	user := createTestUser(t, service, "legacy@example.com", users.StatusActive)
+
+	legacy, hashErr := cryptoutils.NewBcryptHasher(cryptoutils.DefaultBcryptCost).Hash(testPassword)
+	if hashErr != nil {
+		t.Fatalf("hashing the legacy password: %s", hashErr)
+	}
+	user.Password = legacy
+	if err := repository.UpdatePassword(user, users.NewAuditEntry(users.SystemActor, users.AuditActionPasswordReset, user.ID)); err != nil {
+		t.Fatalf("storing the legacy password: %s", err.Message)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		status   int
+		prefix   string
+	}{
+		{name: "wrong password keeps the legacy hash", password: "wrong", status: http.StatusNotFound, prefix: "$2"},
+		{name: "valid password rehashes", password: testPassword, status: http.StatusOK, prefix: "$argon2id$"},
+		{name: "rehashed password still logs in", password: testPassword, status: http.StatusOK, prefix: "$argon2id$"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, status := login(service, "legacy@example.com", test.password); status != test.status {
+				t.Fatalf("got status %d, want %d", status, test.status)
+			}
+
+			stored := &users.User{Email: "legacy@example.com"}
+			if err := repository.FindByEmail(stored); err != nil {
+				t.Fatalf("finding the user: %s", err.Message)
+			}
+			if !strings.HasPrefix(stored.Password, test.prefix) {
+				t.Errorf("got hash %q, want prefix %q", stored.Password, test.prefix)
+			}
+		})
+	}
+
+	audit, err := service.GetAudit(users.AuditRequest{UserID: user.ID})
+	if err != nil {
+		t.Fatalf("getting the audit: %s", err.Message)
+	}
+	if audit.Results[0].Action != users.AuditActionPasswordRehash || audit.Results[0].ActorID != user.ID {
+		t.Errorf("got last audit %+v, want the rehash by the user", audit.Results[0])
+	}
+}
+
+func TestLoginThrottle(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   string
+		attempts []string
+		unlock   bool
+		want     int
+	}{
+		{name: "valid login", status: users.StatusActive, attempts: []string{testPassword}, want: http.StatusOK},
+		{name: "wrong password", status: users.StatusActive, attempts: []string{"wrong"}, want: http.StatusNotFound},
+		{name: "failures below the threshold", status: users.StatusActive, attempts: []string{"wrong", "wrong", testPassword}, want: http.StatusOK},
+		{name: "locked after the threshold", status: users.StatusActive, attempts: []string{"wrong", "wrong", "wrong", testPassword}, want: http.StatusLocked},
+		{name: "unlocked by support", status: users.StatusActive, attempts: []string{"wrong", "wrong", "wrong"}, unlock: true, want: http.StatusOK},
+		{name: "success clears the failures", status: users.StatusActive, attempts: []string{"wrong", "wrong", testPassword, "wrong", "wrong", testPassword}, want: http.StatusOK},
+		{name: "pending user", status: users.StatusPending, attempts: []string{testPassword}, want: http.StatusForbidden},
+		{name: "suspended user", status: users.StatusSuspended, attempts: []string{testPassword}, want: http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service, _ := newTestUsersService()
+			user := createTestUser(t, service, "throttled@example.com", test.status)
+
+			status := 0
+			for _, password := range test.attempts {
+				_, status = login(service, user.Email, password)
+			}
+
+			if test.unlock {
+				if err := service.UnlockUser(user.ID, users.SystemActor); err != nil {
+					t.Fatalf("unlocking: %s", err.Message)
+				}
+				_, status = login(service, user.Email, testPassword)
+			}
+
+			if status != test.want {
+				t.Errorf("got status %d, want %d", status, test.want)
+			}
+		})
+	}
+}
+
+func TestUnlockUserIsAudited(t *testing.T) {
+	service, _ := newTestUsersService()
+	user := createTestUser(t, service, "unlocked@example.com", users.StatusActive)
+
+	actor := users.AuditActor{UserID: 42, ClientIP: "10.0.0.1", RequestID: "request"}
+	if err := service.UnlockUser(user.ID, actor); err != nil {
+		t.Fatalf("unlocking: %s", err.Message)
+	}
+
+	audit, err := service.GetAudit(users.AuditRequest{UserID: user.ID})
+	if err != nil {
+		t.Fatalf("getting the audit: %s", err.Message)
+	}
+	if entry := audit.Results[0]; entry.Action != users.AuditActionUnlock || entry.ActorID != actor.UserID || entry.RequestID != actor.RequestID {
+		t.Errorf("got last audit %+v, want the unlock by %+v", entry, actor)
+	}
+}
+
+func TestConcurrentStatusChanges(t *testing.T) {
+	service, repository := newTestUsersService()
+	user := createTestUser(t, service, "concurrent@example.com", users.StatusActive)
+	deleted := createTestUser(t, service, "deleted@example.com", users.StatusActive)
+	if err := service.DeleteUser(deleted.ID, "", users.SystemActor); err != nil {
+		t.Fatalf("deleting the user: %s", err.Message)
+	}
+
+	suspend, ban := *user, *user
+	suspend.Status, ban.Status = users.StatusSuspended, users.StatusBanned
+	stale := *deleted
+	stale.Password = "changed"
+
+	tests := []struct {
+		name   string
+		write  func() *resterrors.RestErr
+		status int
+	}{
+		{name: "first transition", write: func() *resterrors.RestErr {
+			return repository.UpdateStatus(&suspend, users.NewAuditEntry(users.SystemActor, users.OperationSuspend, user.ID))
+		}, status: http.StatusOK},
+		{name: "transition decided on the same version", write: func() *resterrors.RestErr {
+			return repository.UpdateStatus(&ban, users.NewAuditEntry(users.SystemActor, users.OperationBan, user.ID))
+		}, status: http.StatusConflict},
+		{name: "password of a deleted user", write: func() *resterrors.RestErr {
+			return repository.UpdatePassword(&stale, users.NewAuditEntry(users.SystemActor, users.AuditActionPasswordReset, deleted.ID))
+		}, status: http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if status := statusOf(test.write()); status != test.status {
+				t.Errorf("got status %d, want %d", status, test.status)
+			}
+		})
+	}
+
+	stored, err := service.GetUser(user.ID)
+	if err != nil {
+		t.Fatalf("getting the user: %s", err.Message)
+	}
+	if stored.Status != users.StatusSuspended {
+		t.Errorf("got status %s, want %s", stored.Status, users.StatusSuspended)
+	}
+}
+
+// statusOf returns the status of the RestErr, or 200 without one.
+func statusOf(err *resterrors.RestErr) int {
+	if err == nil {
+		return http.StatusOK
+	}
+
+	return err.Status
+}
+
+func emailsOf(result users.Users) []string {
+	emails := make([]string, len(result))
+	for index := range result {
+		emails[index] = result[index].Email
+	}
+
+	return emails
+}
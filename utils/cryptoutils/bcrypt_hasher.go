@@ -0,0 +1,57 @@
+package cryptoutils
+
+import (
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// DefaultBcryptCost is the bcrypt cost used when none is configured.
+	DefaultBcryptCost = 12
+)
+
+type bcryptHasher struct {
+	cost int
+}
+
+// NewBcryptHasher creates a PasswordHasher for bcrypt using the modular crypt format ($2a$12$...).
+func NewBcryptHasher(cost int) PasswordHasher {
+	return &bcryptHasher{cost: cost}
+}
+
+func (h *bcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+func (h *bcryptHasher) Verify(password string, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (h *bcryptHasher) Identifies(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") ||
+		strings.HasPrefix(encoded, "$2b$") ||
+		strings.HasPrefix(encoded, "$2y$")
+}
+
+func (h *bcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	if err != nil {
+		return true
+	}
+
+	return cost < h.cost
+}
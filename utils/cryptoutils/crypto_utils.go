@@ -2,13 +2,42 @@ package cryptoutils
 
 import (
 	"crypto/md5"
+	"crypto/subtle"
 	"encoding/hex"
+	"errors"
 )
 
 // GetMd5 is a function to cryptograph a string.
+//
+// Deprecated: MD5 is only kept to verify legacy password hashes, use Passwords instead.
 func GetMd5(input string) string {
 	hash := md5.New()
 	hash.Reset()
 	hash.Write([]byte(input))
 	return hex.EncodeToString(hash.Sum(nil))
 }
+
+// md5Hasher verifies the unsalted MD5 digests stored before the adoption of PasswordHasher.
+// It refuses to generate new hashes and always asks for a rehash.
+type md5Hasher struct{}
+
+func (h *md5Hasher) Hash(password string) (string, error) {
+	return "", errors.New("md5 must not be used to hash new passwords")
+}
+
+func (h *md5Hasher) Verify(password string, encoded string) (bool, error) {
+	return subtle.ConstantTimeCompare([]byte(GetMd5(password)), []byte(encoded)) == 1, nil
+}
+
+func (h *md5Hasher) Identifies(encoded string) bool {
+	if len(encoded) != md5.Size*2 {
+		return false
+	}
+
+	_, err := hex.DecodeString(encoded)
+	return err == nil
+}
+
+func (h *md5Hasher) NeedsRehash(encoded string) bool {
+	return true
+}
@@ -0,0 +1,114 @@
+package cryptoutils
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+const (
+	// AlgorithmArgon2id is the name of the argon2id password hashing algorithm.
+	AlgorithmArgon2id = "argon2id"
+	// AlgorithmBcrypt is the name of the bcrypt password hashing algorithm.
+	AlgorithmBcrypt = "bcrypt"
+)
+
+var (
+	// ErrUnknownHashFormat is returned when an encoded hash is not recognized by any hasher.
+	ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+	// Passwords is the access point to the passwordsInterface using argon2id as default algorithm.
+	Passwords passwordsInterface = NewPasswords(NewArgon2idHasher(DefaultArgon2idParams), NewBcryptHasher(DefaultBcryptCost), &md5Hasher{})
+)
+
+// PasswordHasher is an algorithm able to hash passwords into a self-describing encoded string
+// and to verify a password against a string encoded by itself.
+type PasswordHasher interface {
+	// Hash returns the encoded hash for the password.
+	Hash(password string) (string, error)
+	// Verify checks if the password matches the encoded hash.
+	Verify(password string, encoded string) (bool, error)
+	// Identifies returns true when the encoded hash was generated by this algorithm.
+	Identifies(encoded string) bool
+	// NeedsRehash returns true when the encoded hash uses weaker params than the configured ones.
+	NeedsRehash(encoded string) bool
+}
+
+type passwordsInterface interface {
+	Hash(string) (string, error)
+	Verify(string, string) (bool, bool, error)
+	VerifyDummy(string)
+}
+
+type passwords struct {
+	current PasswordHasher
+	hashers []PasswordHasher
+
+	dummyOnce sync.Once
+	dummyHash string
+}
+
+// NewPasswords creates the password handler hashing with current and verifying with current
+// and any of the legacy hashers.
+func NewPasswords(current PasswordHasher, legacy ...PasswordHasher) passwordsInterface {
+	return &passwords{
+		current: current,
+		hashers: append([]PasswordHasher{current}, legacy...),
+	}
+}
+
+// NewPasswordsForAlgorithm creates the password handler hashing with the given algorithm name
+// and still verifying hashes from every supported algorithm.
+func NewPasswordsForAlgorithm(algorithm string) (passwordsInterface, error) {
+	argon2id := NewArgon2idHasher(DefaultArgon2idParams)
+	bcrypt := NewBcryptHasher(DefaultBcryptCost)
+
+	switch algorithm {
+	case AlgorithmArgon2id:
+		return NewPasswords(argon2id, bcrypt, &md5Hasher{}), nil
+	case AlgorithmBcrypt:
+		return NewPasswords(bcrypt, argon2id, &md5Hasher{}), nil
+	}
+
+	return nil, fmt.Errorf("unsupported password hashing algorithm %q", algorithm)
+}
+
+// Hash the password with the current algorithm.
+func (p *passwords) Hash(password string) (string, error) {
+	return p.current.Hash(password)
+}
+
+// Verify the password against the encoded hash, returning if it matches and if the
+// encoded hash should be replaced by a new one generated with the current algorithm.
+func (p *passwords) Verify(password string, encoded string) (bool, bool, error) {
+	for _, hasher := range p.hashers {
+		if !hasher.Identifies(encoded) {
+			continue
+		}
+
+		ok, err := hasher.Verify(password, encoded)
+		if err != nil || !ok {
+			return false, false, err
+		}
+
+		if hasher != p.current {
+			return true, true, nil
+		}
+		return true, hasher.NeedsRehash(encoded), nil
+	}
+
+	return false, false, ErrUnknownHashFormat
+}
+
+// VerifyDummy verifies the password against a hash of the current algorithm, generated on the
+// first call, and ignores the result. It is for the callers without a hash to verify, like the
+// logins with an unknown e-mail, so they take as long as a wrong password.
+func (p *passwords) VerifyDummy(password string) {
+	p.dummyOnce.Do(func() {
+		p.dummyHash, _ = p.current.Hash("dummy password verified for the unknown users")
+	})
+
+	if p.dummyHash != "" {
+		p.current.Verify(password, p.dummyHash)
+	}
+}
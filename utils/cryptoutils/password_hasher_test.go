@@ -0,0 +1,221 @@
+package cryptoutils
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	testPassword  = "Xx9!longpassword"
+	wrongPassword = "Xx9!wrongpassword"
+)
+
+// Cheap params, so the tests don't spend the production cost on every hash.
+var (
+	testArgon2idParams = Argon2idParams{Memory: 1024, Iterations: 2, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	testBcryptCost     = bcrypt.MinCost
+)
+
+func mustHash(t *testing.T, hasher PasswordHasher, password string) string {
+	t.Helper()
+
+	encoded, err := hasher.Hash(password)
+	if err != nil {
+		t.Fatalf("hashing: %s", err)
+	}
+
+	return encoded
+}
+
+func TestHasherRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		hasher PasswordHasher
+		other  PasswordHasher
+		prefix string
+	}{
+		{name: "argon2id", hasher: NewArgon2idHasher(testArgon2idParams), other: NewBcryptHasher(testBcryptCost), prefix: "$argon2id$v=19$m=1024,t=2,p=1$"},
+		{name: "bcrypt", hasher: NewBcryptHasher(testBcryptCost), other: NewArgon2idHasher(testArgon2idParams), prefix: "$2a$04$"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			encoded := mustHash(t, test.hasher, testPassword)
+			if !strings.HasPrefix(encoded, test.prefix) {
+				t.Errorf("got hash %q, want prefix %q", encoded, test.prefix)
+			}
+
+			if again := mustHash(t, test.hasher, testPassword); again == encoded {
+				t.Error("hashing twice should use different salts")
+			}
+
+			if ok, err := test.hasher.Verify(testPassword, encoded); err != nil || !ok {
+				t.Errorf("verifying the password: got %t, %v, want true", ok, err)
+			}
+			if ok, err := test.hasher.Verify(wrongPassword, encoded); err != nil || ok {
+				t.Errorf("verifying a wrong password: got %t, %v, want false", ok, err)
+			}
+
+			if !test.hasher.Identifies(encoded) {
+				t.Error("the hasher should identify its own hash")
+			}
+			if test.other.Identifies(encoded) {
+				t.Error("another hasher shouldn't identify the hash")
+			}
+			if test.hasher.NeedsRehash(encoded) {
+				t.Error("a hash with the current params shouldn't need a rehash")
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	stronger := testArgon2idParams
+	stronger.Memory *= 2
+
+	tests := []struct {
+		name    string
+		hasher  PasswordHasher
+		encoded func(*testing.T) string
+		want    bool
+	}{
+		{name: "argon2id with the same params", hasher: NewArgon2idHasher(testArgon2idParams), encoded: func(t *testing.T) string {
+			return mustHash(t, NewArgon2idHasher(testArgon2idParams), testPassword)
+		}, want: false},
+		{name: "argon2id with less memory", hasher: NewArgon2idHasher(stronger), encoded: func(t *testing.T) string {
+			return mustHash(t, NewArgon2idHasher(testArgon2idParams), testPassword)
+		}, want: true},
+		{name: "argon2id with more memory", hasher: NewArgon2idHasher(testArgon2idParams), encoded: func(t *testing.T) string {
+			return mustHash(t, NewArgon2idHasher(stronger), testPassword)
+		}, want: false},
+		{name: "argon2id with a shorter salt", hasher: NewArgon2idHasher(testArgon2idParams), encoded: func(t *testing.T) string {
+			shorter := testArgon2idParams
+			shorter.SaltLength = 8
+			return mustHash(t, NewArgon2idHasher(shorter), testPassword)
+		}, want: true},
+		{name: "invalid argon2id hash", hasher: NewArgon2idHasher(testArgon2idParams), encoded: func(*testing.T) string {
+			return "$argon2id$v=19$invalid"
+		}, want: true},
+		{name: "bcrypt with the same cost", hasher: NewBcryptHasher(testBcryptCost), encoded: func(t *testing.T) string {
+			return mustHash(t, NewBcryptHasher(testBcryptCost), testPassword)
+		}, want: false},
+		{name: "bcrypt with a lower cost", hasher: NewBcryptHasher(testBcryptCost + 1), encoded: func(t *testing.T) string {
+			return mustHash(t, NewBcryptHasher(testBcryptCost), testPassword)
+		}, want: true},
+		{name: "md5", hasher: &md5Hasher{}, encoded: func(*testing.T) string {
+			return GetMd5(testPassword)
+		}, want: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.hasher.NeedsRehash(test.encoded(t)); got != test.want {
+				t.Errorf("got %t, want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPasswordsVerify(t *testing.T) {
+	argon2id := NewArgon2idHasher(testArgon2idParams)
+	bcryptHasher := NewBcryptHasher(testBcryptCost)
+	passwords := NewPasswords(argon2id, bcryptHasher, &md5Hasher{})
+
+	weaker := testArgon2idParams
+	weaker.Iterations = 1
+
+	tests := []struct {
+		name     string
+		password string
+		encoded  string
+		ok       bool
+		rehash   bool
+		err      error
+	}{
+		{name: "current algorithm", password: testPassword, encoded: mustHash(t, argon2id, testPassword), ok: true},
+		{name: "current algorithm with a wrong password", password: wrongPassword, encoded: mustHash(t, argon2id, testPassword)},
+		{name: "current algorithm with weaker params", password: testPassword, encoded: mustHash(t, NewArgon2idHasher(weaker), testPassword), ok: true, rehash: true},
+		{name: "legacy bcrypt", password: testPassword, encoded: mustHash(t, bcryptHasher, testPassword), ok: true, rehash: true},
+		{name: "legacy bcrypt with a wrong password", password: wrongPassword, encoded: mustHash(t, bcryptHasher, testPassword)},
+		{name: "legacy md5", password: testPassword, encoded: GetMd5(testPassword), ok: true, rehash: true},
+		{name: "legacy md5 with a wrong password", password: wrongPassword, encoded: GetMd5(testPassword)},
+		{name: "unknown format", password: testPassword, encoded: "plain", err: ErrUnknownHashFormat},
+		{name: "empty hash", password: "", encoded: "", err: ErrUnknownHashFormat},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ok, rehash, err := passwords.Verify(test.password, test.encoded)
+			if ok != test.ok || rehash != test.rehash || err != test.err {
+				t.Errorf("got %t, %t, %v, want %t, %t, %v", ok, rehash, err, test.ok, test.rehash, test.err)
+			}
+		})
+	}
+}
+
+func TestLegacyMd5Upgrade(t *testing.T) {
+	passwords := NewPasswords(NewArgon2idHasher(testArgon2idParams), NewBcryptHasher(testBcryptCost), &md5Hasher{})
+
+	legacy := GetMd5(testPassword)
+	ok, rehash, err := passwords.Verify(testPassword, legacy)
+	if err != nil || !ok || !rehash {
+		t.Fatalf("verifying the md5 hash: got %t, %t, %v, want true, true, nil", ok, rehash, err)
+	}
+
+	upgraded, err := passwords.Hash(testPassword)
+	if err != nil {
+		t.Fatalf("rehashing: %s", err)
+	}
+	if !strings.HasPrefix(upgraded, argon2idPrefix) {
+		t.Errorf("got hash %q, want an argon2id one", upgraded)
+	}
+
+	ok, rehash, err = passwords.Verify(testPassword, upgraded)
+	if err != nil || !ok || rehash {
+		t.Errorf("verifying the upgraded hash: got %t, %t, %v, want true, false, nil", ok, rehash, err)
+	}
+
+	if _, err := (&md5Hasher{}).Hash(testPassword); err == nil {
+		t.Error("md5 shouldn't hash new passwords")
+	}
+}
+
+func TestNewPasswordsForAlgorithm(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		prefix    string
+		wantErr   bool
+	}{
+		{algorithm: AlgorithmArgon2id, prefix: argon2idPrefix},
+		{algorithm: AlgorithmBcrypt, prefix: "$2a$"},
+		{algorithm: "md5", wantErr: true},
+		{algorithm: "", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.algorithm, func(t *testing.T) {
+			passwords, err := NewPasswordsForAlgorithm(test.algorithm)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error %t", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			encoded, err := passwords.Hash(testPassword)
+			if err != nil {
+				t.Fatalf("hashing: %s", err)
+			}
+			if !strings.HasPrefix(encoded, test.prefix) {
+				t.Errorf("got hash %q, want prefix %q", encoded, test.prefix)
+			}
+
+			// The other algorithms are still verified, asking for a rehash.
+			if ok, rehash, err := passwords.Verify(testPassword, GetMd5(testPassword)); err != nil || !ok || !rehash {
+				t.Errorf("verifying the md5 hash: got %t, %t, %v, want true, true, nil", ok, rehash, err)
+			}
+		})
+	}
+}
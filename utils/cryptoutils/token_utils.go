@@ -0,0 +1,28 @@
+package cryptoutils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+const (
+	tokenLength = 32
+)
+
+// GenerateToken is a function to create a random URL safe token to be sent to the user.
+func GenerateToken() (string, error) {
+	token := make([]byte, tokenLength)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// HashToken is a function to get the SHA-256 of a token, the only form in which it should be stored.
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -3,6 +3,7 @@ package dateutils
 import "time"
 
 const (
+	apiDayLayout    = "2006-01-02"
 	apiDateLayout   = "2006-01-02T15:04:05Z"
 	apiDbDateLayout = "2006-01-02 15:04:05"
 )
@@ -21,3 +22,24 @@ func GetNowString() string {
 func GetNowDBString() string {
 	return GetNow().Format(apiDbDateLayout)
 }
+
+// GetDBString is a function to format the time.Time as UTC with the pattern prepared for DB.
+func GetDBString(t time.Time) string {
+	return t.UTC().Format(apiDbDateLayout)
+}
+
+// ParseAPIString is a function to parse a date sent to the API, either as a day (2006-01-02) or
+// with the pattern 2006-01-02T15:04:05Z, informing if only the day was given.
+func ParseAPIString(value string) (time.Time, bool, error) {
+	if day, err := time.Parse(apiDayLayout, value); err == nil {
+		return day, true, nil
+	}
+
+	date, err := time.Parse(apiDateLayout, value)
+	return date, false, err
+}
+
+// ParseDBString is a function to parse a date with the pattern prepared for DB as UTC.
+func ParseDBString(value string) (time.Time, error) {
+	return time.Parse(apiDbDateLayout, value)
+}
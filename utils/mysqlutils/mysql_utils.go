@@ -11,17 +11,16 @@ import (
 const (
 	// ErrorNoRows is a message returned by the database to be used as comparission for identify the error.
 	ErrorNoRows = "no rows in result set"
+
+	errorDuplicateEntry = 1062
 )
 
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 // ParseError process the error as a MySQL Error and convert to a errors.RestErr
 func ParseError(err error) *resterrors.RestErr {
-	sqlErr, ok := err.(*mysql.MySQLError)
-
-	if ok {
-		switch sqlErr.Number {
-		case 1062:
-			return resterrors.NewBadRequestError("Invalid data.")
-		}
+	if IsDuplicateEntry(err) {
+		return resterrors.NewBadRequestError("Invalid data.")
 	}
 
 	if strings.Contains(err.Error(), ErrorNoRows) {
@@ -33,3 +32,14 @@ func ParseError(err error) *resterrors.RestErr {
 		errors.New("database error"),
 	)
 }
+
+// EscapeLike escapes the wildcards of the value to be matched literally by a LIKE pattern.
+func EscapeLike(value string) string {
+	return likeEscaper.Replace(value)
+}
+
+// IsDuplicateEntry checks if the error is a MySQL unique constraint violation.
+func IsDuplicateEntry(err error) bool {
+	sqlErr, ok := err.(*mysql.MySQLError)
+	return ok && sqlErr.Number == errorDuplicateEntry
+}
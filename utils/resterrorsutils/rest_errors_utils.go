@@ -0,0 +1,88 @@
+package resterrorsutils
+
+import (
+	"net/http"
+
+	"github.com/migueloli/bookstore_utils-go/resterrors"
+)
+
+// NewForbiddenError creates a RestErr for requests understood but refused.
+func NewForbiddenError(message string) *resterrors.RestErr {
+	return &resterrors.RestErr{
+		Message: message,
+		Status:  http.StatusForbidden,
+		Error:   "forbidden",
+	}
+}
+
+// NewTooManyRequestsError creates a RestErr for requests refused by a rate limit.
+func NewTooManyRequestsError(message string) *resterrors.RestErr {
+	return &resterrors.RestErr{
+		Message: message,
+		Status:  http.StatusTooManyRequests,
+		Error:   "too_many_requests",
+	}
+}
+
+// NewLockedError creates a RestErr for requests refused because the resource is locked.
+func NewLockedError(message string) *resterrors.RestErr {
+	return &resterrors.RestErr{
+		Message: message,
+		Status:  http.StatusLocked,
+		Error:   "locked",
+	}
+}
+
+// NewUnauthorizedError creates a RestErr for requests without valid credentials.
+func NewUnauthorizedError(message string) *resterrors.RestErr {
+	return &resterrors.RestErr{
+		Message: message,
+		Status:  http.StatusUnauthorized,
+		Error:   "unauthorized",
+	}
+}
+
+// NewConflictError creates a RestErr for requests conflicting with the current state of the resource.
+func NewConflictError(message string) *resterrors.RestErr {
+	return &resterrors.RestErr{
+		Message: message,
+		Status:  http.StatusConflict,
+		Error:   "conflict",
+	}
+}
+
+// NewPreconditionFailedError creates a RestErr for conditional requests whose condition doesn't hold.
+func NewPreconditionFailedError(message string) *resterrors.RestErr {
+	return &resterrors.RestErr{
+		Message: message,
+		Status:  http.StatusPreconditionFailed,
+		Error:   "precondition_failed",
+	}
+}
+
+// NewPreconditionRequiredError creates a RestErr for requests that must be conditional.
+func NewPreconditionRequiredError(message string) *resterrors.RestErr {
+	return &resterrors.RestErr{
+		Message: message,
+		Status:  http.StatusPreconditionRequired,
+		Error:   "precondition_required",
+	}
+}
+
+// NewUnprocessableEntityError creates a RestErr for well formed requests with semantic errors.
+func NewUnprocessableEntityError(message string) *resterrors.RestErr {
+	return &resterrors.RestErr{
+		Message: message,
+		Status:  http.StatusUnprocessableEntity,
+		Error:   "unprocessable_entity",
+	}
+}
+
+// NewUnsupportedMediaTypeError creates a RestErr for request bodies of a media type not supported.
+func NewUnsupportedMediaTypeError(message string) *resterrors.RestErr {
+	return &resterrors.RestErr{
+		Message: message,
+		Status:  http.StatusUnsupportedMediaType,
+		Error:   "unsupported_media_type",
+	}
+}